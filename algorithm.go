@@ -0,0 +1,233 @@
+package fst
+
+import (
+	"errors"
+	"hash"
+	"strconv"
+	"sync"
+)
+
+// UnsupportedAlgorithm means that the token is signed with an algorithm that the Converter does not accept.
+var UnsupportedAlgorithm = errors.New("fst: unsupported algorithm")
+
+// Algorithm is the MAC algorithm used to sign the token. Its ID is stored in the token header,
+// so ParseToken knows which algorithm was used.
+type Algorithm uint8
+
+const (
+	// AlgorithmHMAC is HMAC with the ConverterConfig.HashType. It is the default algorithm.
+	AlgorithmHMAC Algorithm = 0
+	// AlgorithmBLAKE2b256 is BLAKE2b-256 in the keyed mode. It is a natively keyed MAC, so it does not need
+	// the HMAC construction and processes a short token in a single compression.
+	// If the secret is longer than 64 bytes, it is hashed with BLAKE2b-512.
+	AlgorithmBLAKE2b256 Algorithm = 1
+	// AlgorithmSipHash128 is SipHash-2-4 with 128 bits output. It is the fastest algorithm for short payloads,
+	// but its security margin is lower, so use it only for internal tokens.
+	// The 16 bytes SipHash key is derived from the secret with BLAKE2b.
+	AlgorithmSipHash128 Algorithm = 2
+	// AlgorithmEd25519 is the Ed25519 signature. Unlike other algorithms, it is asymmetric: the tokens are signed
+	// with the private key and verified with the public key, that can be published, see JWKSHandler.
+	// It needs the keys made by NewEd25519Key or NewEd25519VerificationKey in the KeySource.
+	AlgorithmEd25519 Algorithm = 3
+
+	algorithmCount = 4
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmHMAC:
+		return "HMAC"
+	case AlgorithmBLAKE2b256:
+		return "BLAKE2b-256"
+	case AlgorithmSipHash128:
+		return "SipHash-2-4-128"
+	case AlgorithmEd25519:
+		return "Ed25519"
+	default:
+		return "Algorithm(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+// mac signs and verifies the payload || meta || postfix, where meta is the part of the token before the signature.
+//
+// verify accepts the prefix of the signature to support the truncated signatures,
+// so the caller must check the length of the signature.
+type mac interface {
+	Size() int
+	sum(dst, payload, meta, postfix []byte) []byte
+	verify(signature, payload, meta, postfix []byte) bool
+	// acquire takes the pooled state once for many messages, see macSession.
+	acquire() macSession
+}
+
+// macSession is the pooled state of a mac. It is used by the batches to get the state from the pool
+// once per many messages. It must not be used by several goroutines and must not be used after release.
+type macSession interface {
+	sum(dst, payload, meta, postfix []byte) []byte
+	verify(signature, payload, meta, postfix []byte) bool
+	release()
+}
+
+// macSet has the macs of the symmetric algorithms for a secret. The HMAC is created with the set, and the macs
+// of BLAKE2b and SipHash are created on the first use, because they keep the copies of the secret in the Go heap,
+// that is not locked in RAM. So only the keys that are used with these algorithms have the copies.
+type macSet struct {
+	hashType func() hash.Hash
+	// secret is in the guarded memory of the owner of the set.
+	secret []byte
+	hmac   mac
+
+	once  sync.Once
+	keyed [algorithmCount]mac
+}
+
+func newMacSet(hashType func() hash.Hash, secret []byte) *macSet {
+	return &macSet{
+		hashType: hashType,
+		secret:   secret,
+		hmac:     newMacEngine(hashType, secret),
+	}
+}
+
+// get returns the mac of the algorithm. It returns nil for AlgorithmEd25519 and for the keyed macs
+// that were not created before zero.
+func (s *macSet) get(algorithm Algorithm) mac {
+	if algorithm == AlgorithmHMAC {
+		return s.hmac
+	}
+
+	s.once.Do(func() {
+		s.keyed = newKeyedMacs(s.secret)
+	})
+
+	return s.keyed[algorithm]
+}
+
+// zero zeroes all macs of the set. The keyed macs are never created after it.
+func (s *macSet) zero() {
+	// The Do also waits for the running creation.
+	s.once.Do(func() {})
+
+	for _, m := range append([]mac{s.hmac}, s.keyed[:]...) {
+		if z, ok := m.(zeroer); ok {
+			z.zero()
+		}
+	}
+}
+
+// newKeyedMacs creates the macs of BLAKE2b and SipHash for the secret.
+func newKeyedMacs(secret []byte) [algorithmCount]mac {
+	// The keys are copied, because the secret can be in the guarded memory that is zeroed by Key.Close,
+	// while the keyed macs create the new hashes until they are zeroed.
+	blake2bKey := append([]byte(nil), secret...)
+	if len(blake2bKey) > blake2bMaxKeyLen {
+		h := newBlake2b(64, nil)
+		h.Write(blake2bKey)
+		clear(blake2bKey)
+		blake2bKey = h.Sum(nil)
+	}
+
+	kdf := newBlake2b(16, blake2bKey)
+	kdf.Write([]byte("fst: siphash key"))
+	sipHashKey := kdf.Sum(nil)
+	kdf.(zeroer).zero()
+
+	return [algorithmCount]mac{
+		AlgorithmBLAKE2b256: newKeyedMac(blake2bKey, func() hash.Hash {
+			return newBlake2b(32, blake2bKey)
+		}),
+		AlgorithmSipHash128: newKeyedMac(sipHashKey, func() hash.Hash {
+			return newSipHash(16, sipHashKey)
+		}),
+	}
+}
+
+// keyedMac is a mac over a natively keyed hash, whose Reset returns it to the keyed state.
+type keyedMac struct {
+	size int
+	pool statePool
+	// key is the key of the new hashes. It is zeroed by zero.
+	key []byte
+}
+
+type keyedMacState struct {
+	mac *keyedMac
+	h   hash.Hash
+	buf []byte
+}
+
+func newKeyedMac(key []byte, newHash func() hash.Hash) *keyedMac {
+	m := &keyedMac{
+		size: newHash().Size(),
+		key:  key,
+	}
+	m.pool.new = func() interface{} {
+		return &keyedMacState{
+			mac: m,
+			h:   newHash(),
+			buf: make([]byte, 0, m.size),
+		}
+	}
+	m.pool.zero = func(x interface{}) {
+		state := x.(*keyedMacState)
+		if h, ok := state.h.(zeroer); ok {
+			h.zero()
+		}
+		clear(state.buf[:cap(state.buf)])
+	}
+
+	return m
+}
+
+// Size returns the size of the signature.
+func (m *keyedMac) Size() int {
+	return m.size
+}
+
+func (m *keyedMac) sum(dst, payload, meta, postfix []byte) []byte {
+	state := m.pool.get().(*keyedMacState)
+	dst = state.sum(dst, payload, meta, postfix)
+	m.pool.put(state)
+
+	return dst
+}
+
+func (m *keyedMac) verify(signature, payload, meta, postfix []byte) bool {
+	state := m.pool.get().(*keyedMacState)
+	ok := state.verify(signature, payload, meta, postfix)
+	m.pool.put(state)
+
+	return ok
+}
+
+func (m *keyedMac) acquire() macSession {
+	return m.pool.get().(*keyedMacState)
+}
+
+func (s *keyedMacState) sum(dst, payload, meta, postfix []byte) []byte {
+	s.h.Reset()
+	s.h.Write(payload)
+	s.h.Write(meta)
+	s.h.Write(postfix)
+
+	return s.h.Sum(dst)
+}
+
+func (s *keyedMacState) verify(signature, payload, meta, postfix []byte) bool {
+	s.buf = s.sum(s.buf[:0], payload, meta, postfix)
+
+	// The hashes made after zero have the zeroed key, so their signatures are rejected.
+	return equalPrefix(signature, s.buf) && !s.mac.pool.isClosed()
+}
+
+func (s *keyedMacState) release() {
+	s.mac.pool.put(s)
+}
+
+// zero zeroes the key and the pooled hashes. The mac still works after it, but its sums are not valid
+// and verify rejects all signatures.
+func (m *keyedMac) zero() {
+	m.pool.close()
+	clear(m.key)
+}
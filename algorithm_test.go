@@ -0,0 +1,148 @@
+package fst
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBlake2b(t *testing.T) {
+	key := sequence(64)
+
+	// The keyed vectors are from the BLAKE2 reference known-answer tests, that are also used by golang.org/x/crypto/blake2b.
+	tests := []struct {
+		size     int
+		key      []byte
+		message  []byte
+		expected string
+	}{
+		{64, nil, []byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{64, key, nil, "10ebb67700b1868efb4417987acf4690ae9d972fb7a590c2f02871799aaa4786b5e996e8f0f4eb981fc214b005f42d2ff4233499391653df7aefcbc13fc51568"},
+		{64, key, sequence(1), "961f6dd1e4dd30f63901690c512e78e4b45e4742ed197c3c5e45c549fd25f2e4187b0bc9fe30492b16b0d0bc4ef9b0f34c7003fac09a5ef1532e69430234cebd"},
+		{64, key, sequence(128), "72065ee4dd91c2d8509fa1fc28a37c7fc9fa7d5b3f8ad3d0d7a25626b57b1b44788d4caf806290425f9890a3a2a35a905ab4b37acfd0da6e4517b2525c9651e4"},
+		{64, key, sequence(129), "64475dfe7600d7171bea0b394e27c9b00d8e74dd1e416a79473682ad3dfdbb706631558055cfc8a40e07bd015a4540dcdea15883cbbf31412df1de1cd4152b91"},
+		{64, key, sequence(255), "142709d62e28fcccd0af97fad0f8465b971e82201dc51070faa0372aa43e92484be1c1e73ba10906d5d1853db6a4106e0a7bf9800d373d6dee2d46d62ef2a461"},
+		{32, key, nil, "2fa9fbd9be36437de204e139e97d402bce68c828f43391608c891b5faed8a98a"},
+		{32, key, []byte("abc"), "dff38c978666dff5631db35ca15535520d134f5c8060ea569c6a178ad393719f"},
+	}
+
+	for _, test := range tests {
+		h := newBlake2b(test.size, test.key)
+		h.Write(test.message)
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != test.expected {
+			t.Error("blake2b-", test.size*8, "(", len(test.message), " bytes) = ", actual)
+		}
+
+		// Sum must not change the state and Reset must return to the keyed state.
+		// The message is written in parts, so the blocks are also split between the writes.
+		h.Reset()
+		for message := test.message; len(message) > 0; message = message[min(len(message), 100):] {
+			h.Write(message[:min(len(message), 100)])
+		}
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != test.expected {
+			t.Error("blake2b-", test.size*8, "(", len(test.message), " bytes) after Reset = ", actual)
+		}
+	}
+
+	// The secret longer than 64 bytes is hashed with the unkeyed BLAKE2b-512 and the hash is the key.
+	mac := newKeyedMacs(sequence(100))[AlgorithmBLAKE2b256]
+	expected := "9d7e94dbe055ccde88fd1c3469f833ab288f669b9388d993edac2a0b31c34748"
+	if actual := hex.EncodeToString(mac.sum(nil, []byte("abc"), nil, nil)); actual != expected {
+		t.Error("blake2b-256 with the long secret = ", actual)
+	}
+}
+
+// sequence returns the n bytes 0, 1, 2, ... of the known-answer tests.
+func sequence(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	return b
+}
+
+func TestSipHash(t *testing.T) {
+	key := make([]byte, 16)
+	message := make([]byte, 15)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range message {
+		message[i] = byte(i)
+	}
+
+	h := newSipHash(8, key)
+	h.Write(message[:3])
+	h.Write(message[3:10])
+	h.Write(message[10:])
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != "e545be4961ca29a1" {
+		t.Error("siphash64 = ", actual)
+	}
+
+	h = newSipHash(16, key)
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != "a3817f04ba25a8e66df67214c7550293" {
+		t.Error("siphash128 = ", actual)
+	}
+}
+
+func TestConverter_Algorithms(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmBLAKE2b256, AlgorithmSipHash128} {
+		converter := NewConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			Postfix:        []byte(`postfix`),
+			ExpirationTime: time.Minute * 5,
+			Algorithm:      algorithm,
+		})
+
+		testWithSize(t, converter, 5)
+		testWithSize(t, converter, 100000)
+
+		token := converter.NewToken([]byte(`token`))
+		if Algorithm(token[0]&headerAlgorithmMask) != algorithm {
+			t.Fatal(algorithm, ": header is ", token[0])
+		}
+
+		token[len(token)-1] ^= 1
+		if _, err := converter.ParseToken(token); !errors.Is(err, InvalidSignature) {
+			t.Fatal(algorithm, ": forged token parse err: ", err)
+		}
+	}
+}
+
+func TestConverter_AcceptedAlgorithms(t *testing.T) {
+	oldConverter := NewConverter(&ConverterConfig{
+		SecretKey:          []byte(`secret`),
+		AcceptedAlgorithms: []Algorithm{AlgorithmBLAKE2b256},
+	})
+	newConverter := NewConverter(&ConverterConfig{
+		SecretKey:          []byte(`secret`),
+		Algorithm:          AlgorithmBLAKE2b256,
+		AcceptedAlgorithms: []Algorithm{AlgorithmHMAC},
+	})
+	sipHashConverter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+		Algorithm: AlgorithmSipHash128,
+	})
+
+	if _, err := newConverter.ParseToken(oldConverter.NewToken([]byte(`token`))); err != nil {
+		t.Fatal("HMAC token parse err: ", err)
+	}
+
+	if _, err := oldConverter.ParseToken(newConverter.NewToken([]byte(`token`))); err != nil {
+		t.Fatal("BLAKE2b token parse err: ", err)
+	}
+
+	_, err := newConverter.ParseToken(sipHashConverter.NewToken([]byte(`token`)))
+	if !errors.Is(err, UnsupportedAlgorithm) {
+		t.Fatal("SipHash token parse err: ", err)
+	}
+
+	// The algorithm ID is signed, so it can not be replaced.
+	token := newConverter.NewToken([]byte(`token`))
+	token[0] = byte(AlgorithmHMAC) | headerVarintFlag
+	if _, err = newConverter.ParseToken(token); !errors.Is(err, InvalidSignature) {
+		t.Fatal("token with replaced algorithm parse err: ", err)
+	}
+}
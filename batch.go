@@ -0,0 +1,329 @@
+package fst
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// minBatchChunk is the minimum number of tokens processed by one goroutine of the batch.
+const minBatchChunk = 32
+
+// ParseResult is the result of parsing one token of the batch.
+type ParseResult struct {
+	// Value is the value of the token. It is nil if Err is not nil.
+	Value []byte
+	// Err is the error of parsing the token, like InvalidTokenFormat, InvalidSignature, TokenExpired, KeyClosed.
+	Err error
+}
+
+// runBatch calls the process for the chunks of [0, n). The chunks are processed by at most batchWorkers goroutines.
+func (c *Converter) runBatch(n int, process func(from, to int)) {
+	workers := min(c.batchWorkers, (n+minBatchChunk-1)/minBatchChunk)
+	if workers <= 1 {
+		process(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for from := 0; from < n; from += chunk {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			process(from, to)
+		}(from, min(from+chunk, n))
+	}
+	wg.Wait()
+}
+
+// NewTokens creates a new FST for each of the values like NewToken. This method does not encode the tokens in base64.
+//
+// The clock is read once and the pooled state of the signing key is taken once per chunk, so all tokens
+// of the batch have the same time of issue. If BatchWorkers is set, the chunks are signed in parallel.
+//
+// If the Converter uses a RemoteSigner, NewTokens returns nil when the signer fails.
+// It also returns nil if the Converter can only verify tokens.
+// Use NewTokensContext to get the error.
+func (c *Converter) NewTokens(values [][]byte) [][]byte {
+	tokens, _ := c.NewTokensContext(context.Background(), values)
+	return tokens
+}
+
+// NewTokensContext creates new FSTs like NewTokens, but passes the ctx to the RemoteSigner and returns its error.
+// All values are signed by one call to the RemoteSigner.
+//
+// The Observer gets one event per token with the average latency of the batch, and the AuditHook gets every token.
+func (c *Converter) NewTokensContext(ctx context.Context, values [][]byte) ([][]byte, error) {
+	if c.observer == nil && c.audit == nil {
+		return c.signTokens(ctx, values)
+	}
+
+	start := c.clock()
+	tokens, err := c.signTokens(ctx, values)
+
+	var keyID string
+	if key := c.signingKey(); key != nil && c.signer == nil {
+		keyID = key.ID
+	}
+
+	if c.observer != nil {
+		duration := c.clock().Sub(start) / time.Duration(max(len(values), 1))
+		for range values {
+			c.observe(ctx, OperationNewToken, duration, keyID, err)
+		}
+	}
+
+	if c.audit != nil && err == nil {
+		for _, token := range tokens {
+			c.auditIssued(ctx, token, keyID)
+		}
+	}
+
+	return tokens, err
+}
+
+// signTokens creates the tokens of the NewTokensContext.
+func (c *Converter) signTokens(ctx context.Context, values [][]byte) ([][]byte, error) {
+	if c.signer != nil {
+		return c.signTokensRemote(ctx, values)
+	}
+
+	key, mac, err := c.localSigningMac()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([][]byte, len(values))
+	meta := c.newMeta(c.now())
+	size := c.sizeOfSignature(mac)
+
+	c.runBatch(len(values), func(from, to int) {
+		session := mac.acquire()
+		signature := make([]byte, 0, mac.Size())
+		for i := from; i < to; i++ {
+			signature = session.sum(signature[:0], values[i], meta, c.postfix)
+			tokens[i] = c.buildToken(meta, signature[:size], values[i])
+		}
+		session.release()
+	})
+
+	if key.isClosed() {
+		return nil, KeyClosed
+	}
+
+	return tokens, nil
+}
+
+// signTokensRemote signs all values by one call to the RemoteSigner.
+func (c *Converter) signTokensRemote(ctx context.Context, values [][]byte) ([][]byte, error) {
+	meta := c.newMeta(c.now())
+
+	messages := make([][]byte, len(values))
+	for i, value := range values {
+		messages[i] = c.message(value, meta)
+	}
+
+	signatures, err := c.signer.Sign(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if len(signatures) != len(messages) {
+		return nil, InvalidSignerResponse
+	}
+
+	tokens := make([][]byte, len(values))
+	for i, value := range values {
+		tokens[i] = c.buildToken(meta, signatures[i], value)
+	}
+
+	return tokens, nil
+}
+
+// ParseTokens parses each of the tokens like ParseToken and returns the results in the same order.
+// This method will use the tokens to return the values instead of copying.
+//
+// The clock is read once, the verification keys are loaded once and their pooled states are taken once per chunk.
+// If BatchWorkers is set, the chunks are verified in parallel. The tokens that are not verified by the loaded keys
+// are verified again like ParseToken, if the KeySource can fetch the unknown keys, like KeySet.
+func (c *Converter) ParseTokens(tokens [][]byte) []ParseResult {
+	if c.signer != nil {
+		return c.ParseTokensContext(context.Background(), tokens)
+	}
+
+	results := make([]ParseResult, len(tokens))
+	now := c.now()
+
+	keys := []*Key{c.key}
+	if c.keySource != nil {
+		keys = c.keySource.VerificationKeys()
+	}
+	_, refreshable := c.keySource.(keyRefresher)
+
+	c.runBatch(len(tokens), func(from, to int) {
+		sessions := make([]macSession, len(keys)*algorithmCount)
+		for i := from; i < to; i++ {
+			results[i] = c.parseWithSessions(tokens[i], now, keys, sessions)
+
+			if refreshable && (results[i].Err == InvalidSignature || results[i].Err == KeyClosed) {
+				f, err := c.verifyFrame(context.Background(), tokens[i], now)
+				if err == nil {
+					results[i] = ParseResult{Value: f.payload}
+				}
+			}
+		}
+
+		for _, session := range sessions {
+			if session != nil {
+				session.release()
+			}
+		}
+	})
+
+	return results
+}
+
+// parseWithSessions parses the token like ParseToken, but verifies it with the sessions of the keys.
+// The sessions are indexed by the key index and the algorithm and are acquired on the first use.
+func (c *Converter) parseWithSessions(token []byte, now time.Time, keys []*Key, sessions []macSession) ParseResult {
+	f, err := c.parseFrame(token, now)
+	if err != nil {
+		return ParseResult{Err: err}
+	}
+
+	closed := false
+	for i, key := range keys {
+		mac := key.algorithmMac(f.algorithm)
+		if mac == nil || key.isClosed() || len(f.signature) != c.sizeOfSignature(mac) {
+			closed = closed || key.isClosed()
+			continue
+		}
+
+		session := &sessions[i*algorithmCount+int(f.algorithm)]
+		if *session == nil {
+			*session = mac.acquire()
+		}
+
+		if (*session).verify(f.signature, f.payload, f.meta, c.postfix) {
+			return ParseResult{Value: f.payload}
+		}
+		closed = closed || key.isClosed()
+	}
+
+	return ParseResult{Err: unverifiedError(closed)}
+}
+
+// ParseTokensContext parses the tokens like ParseTokens, but passes the ctx to the RemoteSigner.
+// All tokens that are not in the verified cache are verified by one call to the RemoteSigner.
+// If the signer fails, its error is the Err of these tokens.
+func (c *Converter) ParseTokensContext(ctx context.Context, tokens [][]byte) []ParseResult {
+	if c.signer == nil {
+		return c.ParseTokens(tokens)
+	}
+
+	results := make([]ParseResult, len(tokens))
+	now := c.now()
+
+	var (
+		indexes    []int
+		messages   [][]byte
+		signatures [][]byte
+	)
+	for i, token := range tokens {
+		f, err := c.parseFrame(token, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		message := c.message(f.payload, f.meta)
+		if c.verifiedCache != nil && c.verifiedCache.contains(message, f.signature) {
+			results[i].Value = f.payload
+			continue
+		}
+
+		indexes = append(indexes, i)
+		messages = append(messages, message)
+		signatures = append(signatures, f.signature)
+		results[i].Value = f.payload
+	}
+
+	if len(indexes) == 0 {
+		return results
+	}
+
+	valid, err := c.signer.Verify(ctx, messages, signatures)
+	if err == nil && len(valid) != len(messages) {
+		err = InvalidSignerResponse
+	}
+	for j, i := range indexes {
+		switch {
+		case err != nil:
+			results[i] = ParseResult{Err: err}
+		case !valid[j]:
+			results[i] = ParseResult{Err: InvalidSignature}
+		case c.verifiedCache != nil:
+			c.verifiedCache.add(messages[j], signatures[j])
+		}
+	}
+
+	return results
+}
+
+// NewTokens creates a new FST for each of the values like Converter.NewTokens. This method encodes the tokens in base64.
+//
+// If the Converter uses a RemoteSigner, NewTokens returns nil when the signer fails.
+// Use NewTokensContext to get the error.
+func (c *EncodedConverter) NewTokens(values [][]byte) []string {
+	tokens, _ := c.NewTokensContext(context.Background(), values)
+	return tokens
+}
+
+// NewTokensContext creates new FSTs like NewTokens, but passes the ctx to the RemoteSigner and returns its error.
+func (c *EncodedConverter) NewTokensContext(ctx context.Context, values [][]byte) ([]string, error) {
+	tokens, err := c.converter.NewTokensContext(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedTokens := make([]string, len(tokens))
+	for i, token := range tokens {
+		encodedTokens[i] = base64.URLEncoding.EncodeToString(token)
+	}
+
+	return encodedTokens, nil
+}
+
+// ParseTokens parses each of the tokens like Converter.ParseTokens and returns the results in the same order.
+// This method will copy the tokens' values.
+//
+// The Err of the token that is not valid base64 is the error of base64 decoding.
+func (c *EncodedConverter) ParseTokens(tokens []string) []ParseResult {
+	return c.ParseTokensContext(context.Background(), tokens)
+}
+
+// ParseTokensContext parses the tokens like ParseTokens, but passes the ctx to the RemoteSigner.
+func (c *EncodedConverter) ParseTokensContext(ctx context.Context, tokens []string) []ParseResult {
+	results := make([]ParseResult, len(tokens))
+	indexes := make([]int, 0, len(tokens))
+	decodedTokens := make([][]byte, 0, len(tokens))
+
+	for i, token := range tokens {
+		decodedToken, err := base64.URLEncoding.DecodeString(token)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		indexes = append(indexes, i)
+		decodedTokens = append(decodedTokens, decodedToken)
+	}
+
+	for j, result := range c.converter.ParseTokensContext(ctx, decodedTokens) {
+		results[indexes[j]] = result
+	}
+
+	return results
+}
@@ -0,0 +1,145 @@
+package fst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConverter_Batch(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		for _, algorithm := range []Algorithm{AlgorithmHMAC, AlgorithmBLAKE2b256} {
+			converter := NewConverter(&ConverterConfig{
+				SecretKey:      []byte(`secret`),
+				Postfix:        []byte(`postfix`),
+				ExpirationTime: time.Minute * 5,
+				Algorithm:      algorithm,
+				BatchWorkers:   workers,
+			})
+
+			values := make([][]byte, 200)
+			for i := range values {
+				values[i] = []byte("token" + strconv.Itoa(i))
+			}
+
+			tokens := converter.NewTokens(values)
+			if len(tokens) != len(values) {
+				t.Fatal("NewTokens returned ", len(tokens), " tokens")
+			}
+
+			// The tokens of the batch must be parsed by ParseToken and vice versa.
+			if value, err := converter.ParseToken(tokens[100]); err != nil || !bytes.Equal(value, values[100]) {
+				t.Fatal("Token parse err: ", err)
+			}
+			tokens[150] = converter.NewToken(values[150])
+
+			tokens[3] = tokens[3][:len(tokens[3])-1]
+			tokens[42] = []byte{1}
+
+			for i, result := range converter.ParseTokens(tokens) {
+				switch i {
+				case 3:
+					if !errors.Is(result.Err, InvalidSignature) {
+						t.Fatal("Truncated token parse err: ", result.Err)
+					}
+				case 42:
+					if !errors.Is(result.Err, InvalidTokenFormat) {
+						t.Fatal("Malformed token parse err: ", result.Err)
+					}
+				default:
+					if result.Err != nil {
+						t.Fatal("Token ", i, " parse err: ", result.Err)
+					}
+					if !bytes.Equal(result.Value, values[i]) {
+						t.Fatal("Token ", i, " value is ", string(result.Value))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestConverter_BatchRemoteSigner(t *testing.T) {
+	signer := &countingSigner{RemoteSigner: newTestUnixSigner(t)}
+	converter := NewEncodedConverter(&ConverterConfig{
+		ExpirationTime:    time.Minute * 5,
+		Signer:            signer,
+		VerifiedCacheSize: 16,
+	})
+
+	values := [][]byte{[]byte(`first`), []byte(`second`), []byte(`third`)}
+	tokens := converter.NewTokens(values)
+	if tokens == nil {
+		t.Fatal("NewTokens failed")
+	}
+
+	tokens = append(tokens, "not base64!")
+	for range 2 {
+		results := converter.ParseTokens(tokens)
+		for i := range values {
+			if results[i].Err != nil || !bytes.Equal(results[i].Value, values[i]) {
+				t.Fatal("Token ", i, " parse err: ", results[i].Err)
+			}
+		}
+		if results[3].Err == nil {
+			t.Fatal("Invalid base64 token was parsed")
+		}
+	}
+
+	// The first batch is verified by one call, the second one is cached.
+	if calls := signer.verifyCalls.Load(); calls != 1 {
+		t.Fatal("Verify was called ", calls, " times")
+	}
+}
+
+func benchmarkBatchTokens(converter *Converter) [][]byte {
+	values := make([][]byte, 256)
+	for i := range values {
+		values[i] = benchmarkPayload
+	}
+
+	return converter.NewTokens(values)
+}
+
+func BenchmarkParseToken_Loop(b *testing.B) {
+	converter := NewConverter(&ConverterConfig{SecretKey: []byte(`secret`), ExpirationTime: time.Minute})
+	tokens := benchmarkBatchTokens(converter)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, token := range tokens {
+			if _, err := converter.ParseToken(token); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseTokens(b *testing.B) {
+	converter := NewConverter(&ConverterConfig{SecretKey: []byte(`secret`), ExpirationTime: time.Minute})
+	tokens := benchmarkBatchTokens(converter)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		converter.ParseTokens(tokens)
+	}
+}
+
+func TestConverter_BatchClosed(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{SecretKey: []byte(`secret`)})
+	tokens := converter.NewTokens([][]byte{[]byte(`token`)})
+	converter.Close()
+
+	if _, err := converter.NewTokensContext(context.Background(), [][]byte{[]byte(`token`)}); !errors.Is(err, KeyClosed) {
+		t.Fatal("Batch create after Close err: ", err)
+	}
+
+	if results := converter.ParseTokens(tokens); !errors.Is(results[0].Err, KeyClosed) {
+		t.Fatal("Batch parse after Close err: ", results[0].Err)
+	}
+}
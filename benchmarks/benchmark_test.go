@@ -1,8 +1,12 @@
 package benchmarks
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Eugene-Usachev/fst"
@@ -122,6 +126,34 @@ func BenchmarkUintGen_FST(b *testing.B) {
 	}
 }
 
+// hmacPool is the pooled crypto/hmac that fst used before the precomputed HMAC states.
+// It is used to compare the signing of the uint access token.
+var hmacPool = sync.Pool{
+	New: func() interface{} {
+		return hmac.New(sha256.New, bkey1)
+	},
+}
+
+func BenchmarkUintGen_FST_HMACPool(b *testing.B) {
+	bid := U2B(id)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mac := hmacPool.Get().(hash.Hash)
+		mac.Reset()
+		mac.Write(bid)
+		signature := mac.Sum(make([]byte, 0, mac.Size()))
+		hmacPool.Put(mac)
+
+		token := make([]byte, 0, 1+len(signature)+len(bid))
+		token = append(token, byte(len(signature)))
+		token = append(token, signature...)
+		token = append(token, bid...)
+		if len(token) < 1 {
+		}
+	}
+}
+
 func BenchmarkBigStringGen_GoJose(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		token, err := v5.NewRefreshToken(message1)
@@ -240,6 +272,22 @@ func BenchmarkUintParse_FST(b *testing.B) {
 	}
 }
 
+func BenchmarkUintParse_FST_HMACPool(b *testing.B) {
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		signature, payload := uintTokenFST[1:1+uintTokenFST[0]], uintTokenFST[1+uintTokenFST[0]:]
+
+		mac := hmacPool.Get().(hash.Hash)
+		mac.Reset()
+		mac.Write(payload)
+		valid := hmac.Equal(signature, mac.Sum(nil))
+		hmacPool.Put(mac)
+		if !valid {
+		}
+	}
+}
+
 func BenchmarkBigStringParse_GoJose(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		pass, err := v5.ParseRefreshToken(bigStringTokenV5)
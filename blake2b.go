@@ -0,0 +1,184 @@
+package fst
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// blake2b is BLAKE2b (RFC 7693) with an optional key. It implements hash.Hash, Reset returns it to the keyed state.
+
+const (
+	blake2bBlockSize = 128
+	blake2bMaxKeyLen = 64
+)
+
+var blake2bIV = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+var blake2bSigma = [10][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+}
+
+type blake2b struct {
+	h    [8]uint64
+	t    [2]uint64
+	buf  [blake2bBlockSize]byte
+	n    int
+	size int
+
+	key    [blake2bBlockSize]byte
+	keyLen int
+	// initH is the initial state and keyedH is the state after the key block.
+	initH  [8]uint64
+	keyedH [8]uint64
+}
+
+// newBlake2b returns BLAKE2b with the size bytes output. The key must not be longer than 64 bytes.
+func newBlake2b(size int, key []byte) hash.Hash {
+	d := &blake2b{
+		size:   size,
+		keyLen: min(len(key), blake2bMaxKeyLen),
+	}
+	copy(d.key[:], key[:d.keyLen])
+
+	d.initH = blake2bIV
+	d.initH[0] ^= 0x01010000 ^ uint64(d.keyLen)<<8 ^ uint64(d.size)
+	if d.keyLen > 0 {
+		// The key block is processed as the first block of the message, so we compress it once here.
+		// It is not the last block only if the message is not empty, Sum handles the empty message.
+		d.h = d.initH
+		d.buf = d.key
+		d.addCounter(blake2bBlockSize)
+		d.compress(false)
+		d.keyedH = d.h
+	}
+	d.Reset()
+
+	return d
+}
+
+// zero zeroes the key and the states derived from it.
+func (d *blake2b) zero() {
+	*d = blake2b{size: d.size}
+}
+
+func (d *blake2b) Size() int { return d.size }
+
+func (d *blake2b) BlockSize() int { return blake2bBlockSize }
+
+func (d *blake2b) Reset() {
+	d.h = d.initH
+	d.t = [2]uint64{}
+	d.n = 0
+
+	if d.keyLen > 0 {
+		d.h = d.keyedH
+		d.t[0] = blake2bBlockSize
+	}
+}
+
+func (d *blake2b) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		// The last block must be compressed with the final flag, so we compress the buffer only if there is more data.
+		if d.n == blake2bBlockSize {
+			d.addCounter(blake2bBlockSize)
+			d.compress(false)
+			d.n = 0
+		}
+
+		n := copy(d.buf[d.n:], p)
+		d.n += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func (d *blake2b) Sum(b []byte) []byte {
+	final := *d
+	if final.keyLen > 0 && final.t == [2]uint64{blake2bBlockSize, 0} && final.n == 0 {
+		// The message is empty, so the key block is the last one.
+		final.h = final.initH
+		final.t = [2]uint64{}
+		final.buf = final.key
+		final.n = blake2bBlockSize
+	}
+	final.addCounter(uint64(final.n))
+	clear(final.buf[final.n:])
+	final.compress(true)
+
+	var out [64]byte
+	for i, h := range final.h {
+		binary.LittleEndian.PutUint64(out[i*8:], h)
+	}
+
+	return append(b, out[:d.size]...)
+}
+
+func (d *blake2b) addCounter(n uint64) {
+	var carry uint64
+	d.t[0], carry = bits.Add64(d.t[0], n, 0)
+	d.t[1] += carry
+}
+
+func (d *blake2b) compress(last bool) {
+	var m [16]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(d.buf[i*8:])
+	}
+
+	v0, v1, v2, v3, v4, v5, v6, v7 := d.h[0], d.h[1], d.h[2], d.h[3], d.h[4], d.h[5], d.h[6], d.h[7]
+	v8, v9, v10, v11 := blake2bIV[0], blake2bIV[1], blake2bIV[2], blake2bIV[3]
+	v12, v13, v14, v15 := blake2bIV[4]^d.t[0], blake2bIV[5]^d.t[1], blake2bIV[6], blake2bIV[7]
+	if last {
+		v14 = ^v14
+	}
+
+	for round := 0; round < 12; round++ {
+		s := &blake2bSigma[round%10]
+		v0, v4, v8, v12 = blake2bG(v0, v4, v8, v12, m[s[0]&15], m[s[1]&15])
+		v1, v5, v9, v13 = blake2bG(v1, v5, v9, v13, m[s[2]&15], m[s[3]&15])
+		v2, v6, v10, v14 = blake2bG(v2, v6, v10, v14, m[s[4]&15], m[s[5]&15])
+		v3, v7, v11, v15 = blake2bG(v3, v7, v11, v15, m[s[6]&15], m[s[7]&15])
+		v0, v5, v10, v15 = blake2bG(v0, v5, v10, v15, m[s[8]&15], m[s[9]&15])
+		v1, v6, v11, v12 = blake2bG(v1, v6, v11, v12, m[s[10]&15], m[s[11]&15])
+		v2, v7, v8, v13 = blake2bG(v2, v7, v8, v13, m[s[12]&15], m[s[13]&15])
+		v3, v4, v9, v14 = blake2bG(v3, v4, v9, v14, m[s[14]&15], m[s[15]&15])
+	}
+
+	d.h[0] ^= v0 ^ v8
+	d.h[1] ^= v1 ^ v9
+	d.h[2] ^= v2 ^ v10
+	d.h[3] ^= v3 ^ v11
+	d.h[4] ^= v4 ^ v12
+	d.h[5] ^= v5 ^ v13
+	d.h[6] ^= v6 ^ v14
+	d.h[7] ^= v7 ^ v15
+}
+
+func blake2bG(a, b, c, d, x, y uint64) (uint64, uint64, uint64, uint64) {
+	a += b + x
+	d = bits.RotateLeft64(d^a, -32)
+	c += d
+	b = bits.RotateLeft64(b^c, -24)
+	a += b + y
+	d = bits.RotateLeft64(d^a, -16)
+	c += d
+	b = bits.RotateLeft64(b^c, -63)
+
+	return a, b, c, d
+}
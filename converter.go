@@ -1,16 +1,37 @@
 package fst
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
+	"encoding/binary"
 	"errors"
 	"hash"
-	"sync"
+	"strconv"
 	"time"
 )
 
 // Token layout:
-// [8 bytes expiration?] [N bytes signatureLen] [signature] [payload]
+// [1 byte header?] [timestamp?] [N bytes signatureLen] [signature] [payload]
+//
+// The header is present only if the Converter uses a non-default Algorithm, AcceptedAlgorithms or TimeResolution:
+// [bits 0-3 algorithm ID] [bits 4-6 time encoding] [bit 7 varint framing]
+//
+// The timestamp is the time of issue. It is present only if the Converter uses ExpirationTime.
+// It is 8 bytes of Unix seconds or, if the time encoding is not zero, a uvarint of the time units since 2024-01-01 UTC.
+//
+// The lengths are encoded as the minimal uvarint if the varint framing bit is set. New tokens with the header always set it.
+// Otherwise, the length is 1 byte if it is less than 255, else 0xff and 2 bytes if it is less than 65535,
+// else 0xff 0xff 0xff and 3 bytes (little-endian).
+//
+// Algorithm ID 15 is used by the segmented tokens, see segments.go.
+//
+// The format is specified in SPEC.md and is checked by the test vectors of the testvectors package.
+
+const (
+	headerAlgorithmMask = 0x0f
+	headerTimeMask      = 0x70
+	headerTimeShift     = 4
+	headerVarintFlag    = 0x80
+)
 
 var (
 	// InvalidTokenFormat means that the token is malformed.
@@ -62,11 +83,27 @@ var (
 //	fmt.Println(string(value)) // token
 type Converter struct {
 	timeBeforeExpire int64
+	expirationTime   time.Duration
+	expires          bool
+	timeEncoding     byte
 
-	secretKey []byte
+	key       *Key
+	keySource KeySource
 	postfix   []byte
 
-	hmacPool sync.Pool
+	signer        RemoteSigner
+	verifiedCache *signatureCache
+
+	withHeader bool
+	algorithm  Algorithm
+	accepted   [algorithmCount]bool
+
+	signatureSize int
+	batchWorkers  int
+	observer      Observer
+	audit         AuditHook
+	clock         func() time.Time
+
 	hashType hash.Hash
 }
 
@@ -79,6 +116,28 @@ type Converter struct {
 // ExpirationTime is the expiration time of the token. It is zero by default and will not expire.
 //
 // HashType is the hash function used to sign the token.
+//
+// KeySource is the source of the keys. If it is set, SecretKey and HashType are ignored.
+//
+// Signer is the remote signer that signs and verifies the tokens. If it is set, SecretKey, HashType and KeySource are ignored.
+//
+// VerifiedCacheSize is the number of verified signatures that are cached to limit round-trips to the Signer.
+//
+// Algorithm is the MAC algorithm used to sign the token. It is AlgorithmHMAC by default.
+//
+// AcceptedAlgorithms are the algorithms that ParseToken accepts in addition to the Algorithm.
+//
+// SignatureSize is the size of the truncated signature. It is zero by default and the signature is not truncated.
+//
+// TimeResolution is the resolution of the token timestamp. It is zero by default and the timestamp is 8 bytes of Unix seconds.
+//
+// BatchWorkers is the maximum number of goroutines used by NewTokens and ParseTokens. It is zero by default and the batches are sequential.
+//
+// Observer observes the creation and the parsing of the tokens. It is nil by default.
+//
+// Audit is notified about the issued tokens and the revoked sessions. It is nil by default.
+//
+// Now returns the current time. It is time.Now by default.
 type ConverterConfig struct {
 	// SecretKey is the secret used to sign the token.
 	SecretKey []byte
@@ -88,136 +147,584 @@ type ConverterConfig struct {
 	ExpirationTime time.Duration
 	// HashType is the hash function used to sign the token.
 	HashType func() hash.Hash
+	// KeySource is the source of the keys. If it is set, SecretKey and HashType are ignored.
+	KeySource KeySource
+	// Signer is the remote signer that signs and verifies the tokens. If it is set, SecretKey, HashType and KeySource are ignored.
+	// It signs only with HMAC, so it can not be used with another Algorithm, AcceptedAlgorithms or SignatureSize.
+	Signer RemoteSigner
+	// VerifiedCacheSize is the number of verified signatures that are cached to limit round-trips to the Signer.
+	// It is zero by default and nothing is cached.
+	VerifiedCacheSize int
+	// Algorithm is the MAC algorithm used to sign the token. It is AlgorithmHMAC by default.
+	//
+	// If it is not AlgorithmHMAC or AcceptedAlgorithms is set, the token starts with a header with the algorithm ID.
+	// Such tokens can be parsed only by the Converters that also use the header.
+	// AlgorithmEd25519 requires a KeySource with the Ed25519 keys, like StaticKeySource or KeySet.
+	Algorithm Algorithm
+	// AcceptedAlgorithms are the algorithms that ParseToken accepts in addition to the Algorithm.
+	// It is useful to migrate from one algorithm to another.
+	AcceptedAlgorithms []Algorithm
+	// SignatureSize is the size of the truncated signature in bytes. It must not be less than MinSignatureSize.
+	// It is zero by default and the signature is not truncated. It can not be used with Signer.
+	//
+	// ParseToken accepts only the signatures of this size.
+	SignatureSize int
+	// TimeResolution is the resolution of the token timestamp: time.Second, time.Millisecond, time.Microsecond or time.Nanosecond.
+	// If it is set, the timestamp is stored compactly as a uvarint of the time units since 2024-01-01 UTC
+	// and the resolution is recorded in the token header. It allows sub-second ExpirationTime.
+	//
+	// It is zero by default and the timestamp is 8 bytes of Unix seconds.
+	TimeResolution time.Duration
+	// BatchWorkers is the maximum number of goroutines used by NewTokens and ParseTokens.
+	// It is zero by default and the batches are processed in the calling goroutine.
+	// Small batches are not split, because starting a goroutine costs more than signing a few tokens.
+	BatchWorkers int
+	// Observer observes every NewToken and ParseToken, including the ones made by the wrappers like EncodedConverter,
+	// with the outcome, the latency and the key ID. NewTokens reports one event per token.
+	// ParseTokens and the streams are not observed.
+	// It is nil by default and nothing is measured.
+	Observer Observer
+	// Audit is notified about every token issued by NewToken and NewTokens, including the ones made by the wrappers
+	// like EncodedConverter, and about the sessions revoked by the ReferenceConverter, see AuditLogger.
+	// The segmented tokens and the streams are not audited. It is nil by default.
+	Audit AuditHook
+	// Now returns the current time used to issue the tokens and to check their expiration. The wrappers of the Converter,
+	// like SlidingSession, URLSigner, ReferenceConverter and JWTBridge, the audit events and the Observer latencies use it too.
+	// It is time.Now by default. A fixed time makes the tokens reproducible, see the testvectors package.
+	Now func() time.Time
 }
 
+// MinSignatureSize is the minimum size of the truncated signature. 16 bytes give 128 bits of security against forgery.
+const MinSignatureSize = 16
+
 // NewConverter creates a new instance of the Converter based on the provided fst.ConverterConfig.
 //
 // An example of usage can be found at Converter.
 func NewConverter(cfg *ConverterConfig) *Converter {
-	if cfg.HashType == nil {
-		cfg.HashType = sha256.New
-	}
-
 	converter := &Converter{
-		secretKey:        cfg.SecretKey,
+		keySource:        cfg.KeySource,
 		postfix:          cfg.Postfix,
 		timeBeforeExpire: int64(cfg.ExpirationTime.Seconds()),
+		expirationTime:   cfg.ExpirationTime,
+		batchWorkers:     cfg.BatchWorkers,
+		observer:         cfg.Observer,
+		audit:            cfg.Audit,
+		clock:            cfg.Now,
+
+		withHeader: cfg.Algorithm != AlgorithmHMAC || len(cfg.AcceptedAlgorithms) > 0 || cfg.TimeResolution != 0,
+		algorithm:  cfg.Algorithm,
+	}
+
+	timeEncoding, ok := timeEncodingFor(cfg.TimeResolution)
+	if !ok {
+		panic("fst: unsupported TimeResolution " + cfg.TimeResolution.String())
+	}
+	converter.timeEncoding = timeEncoding
+
+	if converter.clock == nil {
+		converter.clock = time.Now
+	}
+
+	if timeEncoding == timeEncodingUnixSeconds {
+		converter.expires = converter.timeBeforeExpire != 0
+	} else {
+		converter.expires = cfg.ExpirationTime > 0
+	}
+
+	if cfg.SignatureSize != 0 {
+		if cfg.SignatureSize < MinSignatureSize {
+			panic("fst: SignatureSize must not be less than " + strconv.Itoa(MinSignatureSize))
+		}
+		converter.signatureSize = cfg.SignatureSize
+	}
 
-		hmacPool: sync.Pool{
-			New: func() interface{} {
-				return hmac.New(cfg.HashType, cfg.SecretKey)
-			},
-		},
+	for _, algorithm := range append([]Algorithm{cfg.Algorithm}, cfg.AcceptedAlgorithms...) {
+		if int(algorithm) >= algorithmCount {
+			panic("fst: unknown algorithm " + algorithm.String())
+		}
+		converter.accepted[algorithm] = true
+	}
+
+	if cfg.Signer != nil {
+		if cfg.Algorithm != AlgorithmHMAC || len(cfg.AcceptedAlgorithms) > 0 || cfg.SignatureSize != 0 {
+			panic("fst: Signer can not be used with Algorithm, AcceptedAlgorithms or SignatureSize")
+		}
+
+		converter.signer = cfg.Signer
+		converter.keySource = nil
+		if cfg.VerifiedCacheSize > 0 {
+			converter.verifiedCache = newSignatureCache(cfg.VerifiedCacheSize)
+		}
+
+		return converter
+	}
+
+	if cfg.KeySource == nil {
+		if cfg.Algorithm == AlgorithmEd25519 {
+			panic("fst: AlgorithmEd25519 requires a KeySource with the Ed25519 keys")
+		}
+
+		converter.key = NewKey("", cfg.SecretKey, cfg.HashType)
 	}
 
 	return converter
 }
 
+func (c *Converter) signingKey() *Key {
+	if c.keySource != nil {
+		return c.keySource.SigningKey()
+	}
+
+	return c.key
+}
+
 // NewToken creates a new FST with the provided value. This method does not encode the token in base64.
+//
+// If the Converter uses a RemoteSigner, NewToken returns nil when the signer fails.
+// It also returns nil if the Converter can only verify tokens, see VerificationOnly.
+// Use NewTokenContext to get the error.
 func (c *Converter) NewToken(value []byte) []byte {
-	var exTime []byte
-	isWithExpirationTime := c.timeBeforeExpire != 0
+	token, _ := c.newTokenWithMeta(context.Background(), c.newMeta(c.now()), value)
+	return token
+}
+
+// NewTokenContext creates a new FST with the provided value like NewToken,
+// but passes the ctx to the RemoteSigner and returns its error.
+func (c *Converter) NewTokenContext(ctx context.Context, value []byte) ([]byte, error) {
+	return c.newTokenWithMeta(ctx, c.newMeta(c.now()), value)
+}
+
+// newTokenWithMeta creates a new FST with the meta made by newMeta.
+func (c *Converter) newTokenWithMeta(ctx context.Context, meta, value []byte) ([]byte, error) {
+	if c.observer == nil && c.audit == nil {
+		return c.signToken(ctx, meta, value)
+	}
+
+	start := c.clock()
+	token, err := c.signToken(ctx, meta, value)
+
+	var keyID string
+	if key := c.signingKey(); key != nil && c.signer == nil {
+		keyID = key.ID
+	}
+
+	if c.observer != nil {
+		c.observe(ctx, OperationNewToken, c.clock().Sub(start), keyID, err)
+	}
+
+	if c.audit != nil && err == nil {
+		c.auditIssued(ctx, token, keyID)
+	}
+
+	return token, err
+}
+
+func (c *Converter) signToken(ctx context.Context, meta, value []byte) ([]byte, error) {
+	if c.signer == nil {
+		key, mac, err := c.localSigningMac()
+		if err != nil {
+			return nil, err
+		}
 
-	// Create the signature
-	mac := c.hmacPool.Get().(hash.Hash)
-	mac.Reset()
-	mac.Write(value)
+		signature := mac.sum(make([]byte, 0, mac.Size()), value, meta, c.postfix)
+		if key.isClosed() {
+			// The key was closed while signing, so the signature can be made with the zeroed key.
+			return nil, KeyClosed
+		}
+
+		return c.buildToken(meta, signature[:c.sizeOfSignature(mac)], value), nil
+	}
+
+	signatures, err := c.signer.Sign(ctx, [][]byte{c.message(value, meta)})
+	if err != nil {
+		return nil, err
+	}
+	if len(signatures) != 1 {
+		return nil, InvalidSignerResponse
+	}
+
+	return c.buildToken(meta, signatures[0], value), nil
+}
+
+// localSigningMac returns the signing key and its mac. It returns KeyClosed if the key is closed
+// and VerificationOnly if it can not sign. The caller must check that the key is not closed after signing.
+func (c *Converter) localSigningMac() (*Key, mac, error) {
+	key := c.signingKey()
+	mac := key.signingMac(c.algorithm)
+	if mac == nil {
+		if key != nil && key.isClosed() {
+			return nil, nil, KeyClosed
+		}
+
+		return nil, nil, VerificationOnly
+	}
+
+	return key, mac, nil
+}
+
+// derivedSigningMac returns the signing key and its mac derived for the purpose. It returns KeyClosed if the key is closed
+// and SymmetricKeyRequired if it has no secret. The caller must check that the key is not closed after signing.
+func (c *Converter) derivedSigningMac(purpose int) (*Key, mac, error) {
+	key := c.signingKey()
+	if key != nil && key.isClosed() {
+		return nil, nil, KeyClosed
+	}
+
+	mac := key.derivedMac(purpose, c.algorithm)
+	if mac == nil {
+		return nil, nil, SymmetricKeyRequired
+	}
+
+	return key, mac, nil
+}
+
+// now returns the current time if the Converter uses ExpirationTime. Otherwise, the time is not needed and it returns zero.
+func (c *Converter) now() time.Time {
+	if !c.expires {
+		return time.Time{}
+	}
+
+	return c.clock()
+}
+
+// newMeta returns the part of the new token before the signature: the header and the timestamp.
+func (c *Converter) newMeta(now time.Time) []byte {
+	if !c.withHeader && !c.expires {
+		return nil
+	}
 
-	if isWithExpirationTime {
-		exTime = getBytesForInt64(time.Now().Unix())
-		mac.Write(exTime)
+	meta := make([]byte, 0, 1+binary.MaxVarintLen64)
+	if c.withHeader {
+		header := byte(c.algorithm) | headerVarintFlag
+		if c.expires {
+			header |= c.timeEncoding << headerTimeShift
+		}
+		meta = append(meta, header)
 	}
 
-	if c.postfix != nil {
-		mac.Write(c.postfix)
+	if c.expires {
+		meta = appendTimestamp(meta, c.timeEncoding, now)
 	}
 
-	signature := make([]byte, 0, mac.Size())
-	signature = mac.Sum(signature)
+	return meta
+}
+
+// message returns the data that is signed: the payload, the meta and the postfix.
+func (c *Converter) message(payload, meta []byte) []byte {
+	message := make([]byte, 0, len(payload)+len(meta)+len(c.postfix))
+	message = append(message, payload...)
+	message = append(message, meta...)
+	message = append(message, c.postfix...)
 
-	c.hmacPool.Put(mac)
+	return message
+}
 
+func (c *Converter) buildToken(meta, signature, value []byte) []byte {
 	var token []byte
 
-	if !isWithExpirationTime {
-		token = make([]byte, 0, len(value)+len(signature)+getSizeForLen(len(signature)))
-		token = append(token, getBytesFromLen(len(signature))...)
-		token = append(token, signature...)
-		token = append(token, value...)
+	if c.withHeader {
+		token = make([]byte, 0, len(meta)+getSizeForUvarint(uint64(len(signature)))+len(signature)+len(value))
+		token = append(token, meta...)
+		token = binary.AppendUvarint(token, uint64(len(signature)))
 	} else {
-		token = make([]byte, 0, len(value)+len(signature)+getSizeForLen(len(signature)+8))
-		token = append(token, exTime...)
-		token = append(token, getBytesFromLen(len(signature))...)
-		token = append(token, signature...)
-		token = append(token, value...)
+		token = make([]byte, 0, len(meta)+getSizeForLen(len(signature))+len(signature)+len(value))
+		token = append(token, meta...)
+		token = appendLen(token, len(signature))
 	}
 
+	token = append(token, signature...)
+	token = append(token, value...)
+
 	return token
 }
 
 // ParseToken parses a FST and returns the value.
 // This method will use token to return the value instead of copying.
 //
-// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired.
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, UnsupportedAlgorithm
+// and KeyClosed if the token is not verified and the key of the Converter or of the KeySource is closed.
+// If the Converter uses a RemoteSigner, it can also return the error of the signer.
 func (c *Converter) ParseToken(token []byte) ([]byte, error) {
-	if len(token) < 11 {
-		if c.timeBeforeExpire == 0 {
-			if len(token) < 3 {
-				return nil, InvalidTokenFormat
-			}
-		} else {
-			return nil, InvalidTokenFormat
+	f, err := c.parseVerifiedFrame(context.Background(), token, c.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return f.payload, nil
+}
+
+// ParseTokenContext parses a FST like ParseToken, but passes the ctx to the RemoteSigner.
+func (c *Converter) ParseTokenContext(ctx context.Context, token []byte) ([]byte, error) {
+	f, err := c.parseVerifiedFrame(ctx, token, c.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return f.payload, nil
+}
+
+// parseVerifiedFrame splits the token into its parts, checks the expiration time at the now and verifies the signature.
+func (c *Converter) parseVerifiedFrame(ctx context.Context, token []byte, now time.Time) (frame, error) {
+	if c.observer == nil {
+		return c.verifyFrame(ctx, token, now)
+	}
+
+	start := c.clock()
+	f, err := c.verifyFrame(ctx, token, now)
+	c.observe(ctx, OperationParseToken, c.clock().Sub(start), f.keyID, err)
+
+	return f, err
+}
+
+func (c *Converter) verifyFrame(ctx context.Context, token []byte, now time.Time) (frame, error) {
+	f, err := c.parseFrame(token, now)
+	if err != nil {
+		return f, err
+	}
+
+	if c.signer != nil {
+		return f, c.verifyRemote(ctx, &f)
+	}
+
+	if c.keySource == nil {
+		if !c.verify(c.key, &f) {
+			return f, unverifiedError(c.key.isClosed())
+		}
+
+		return f, nil
+	}
+
+	err = c.verifyKeys(c.keySource.VerificationKeys(), &f)
+	if err == nil {
+		return f, nil
+	}
+
+	// The token may be signed with a new key that the KeySource has not fetched yet.
+	if refresher, ok := c.keySource.(keyRefresher); ok && refresher.refreshUnknown(ctx) {
+		err = c.verifyKeys(c.keySource.VerificationKeys(), &f)
+	}
+
+	return f, err
+}
+
+// verifyKeys verifies the frame with the keys and sets its keyID to the ID of the key that made the signature.
+// It returns KeyClosed if no key made the signature and some of them are closed, because the token
+// could be signed with the closed key, otherwise it returns InvalidSignature.
+func (c *Converter) verifyKeys(keys []*Key, f *frame) error {
+	closed := false
+	for _, key := range keys {
+		if c.verify(key, f) {
+			f.keyID = key.ID
+			return nil
+		}
+		closed = closed || key.isClosed()
+	}
+
+	return unverifiedError(closed)
+}
+
+// unverifiedError returns the error of the token that is not verified by the keys.
+func unverifiedError(closed bool) error {
+	if closed {
+		return KeyClosed
+	}
+
+	return InvalidSignature
+}
+
+// verifyRemote verifies the signature of the frame with the RemoteSigner and the verified cache.
+func (c *Converter) verifyRemote(ctx context.Context, f *frame) error {
+	message := c.message(f.payload, f.meta)
+	if c.verifiedCache != nil && c.verifiedCache.contains(message, f.signature) {
+		return nil
+	}
+
+	valid, err := c.signer.Verify(ctx, [][]byte{message}, [][]byte{f.signature})
+	if err != nil {
+		return err
+	}
+	if len(valid) != 1 {
+		return InvalidSignerResponse
+	}
+
+	if !valid[0] {
+		return InvalidSignature
+	}
+
+	if c.verifiedCache != nil {
+		c.verifiedCache.add(message, f.signature)
+	}
+
+	return nil
+}
+
+// frame is a token split into its parts.
+type frame struct {
+	algorithm    Algorithm
+	timeEncoding byte
+	varint       bool
+	// meta is the part of the token before the signature: the header and the timestamp.
+	meta []byte
+	// issuedAt is the time of issue. It is zero if the Converter does not use ExpirationTime.
+	issuedAt  time.Time
+	signature []byte
+	payload   []byte
+	// keyID is the ID of the key that verified the frame.
+	keyID string
+}
+
+// parseFrame splits the token into its parts and checks the algorithm and the expiration time.
+func (c *Converter) parseFrame(token []byte, now time.Time) (frame, error) {
+	f, err := c.decodeFrame(token)
+	if err != nil {
+		return f, err
+	}
+
+	if int(f.algorithm) >= algorithmCount || !c.accepted[f.algorithm] {
+		return f, UnsupportedAlgorithm
+	}
+
+	if c.expires && c.isExpired(f.issuedAt, f.timeEncoding, now) {
+		return f, TokenExpired
+	}
+
+	return f, nil
+}
+
+// decodeFrame splits the token into its parts. It checks only the format of the token.
+func (c *Converter) decodeFrame(token []byte) (frame, error) {
+	var f frame
+	offset := 0
+
+	if c.withHeader {
+		if len(token) == 0 {
+			return f, InvalidTokenFormat
+		}
+
+		header := token[0]
+		f.varint = header&headerVarintFlag != 0
+		f.algorithm = Algorithm(header & headerAlgorithmMask)
+
+		f.timeEncoding = (header & headerTimeMask) >> headerTimeShift
+		if f.timeEncoding >= timeEncodingCount || (!c.expires && f.timeEncoding != timeEncodingUnixSeconds) {
+			return f, InvalidTokenFormat
 		}
+
+		offset = 1
 	}
 
-	isWithExpirationTime := c.timeBeforeExpire != 0
+	if c.expires {
+		issuedAt, n := readTimestamp(token[offset:], f.timeEncoding)
+		if n <= 0 {
+			return f, InvalidTokenFormat
+		}
+
+		f.issuedAt = issuedAt
+		offset += n
+	}
 
-	var payloadOffset int
-	if isWithExpirationTime {
-		exTime := getInt64(token)
+	// At least 1 byte of the signature length, 1 byte of the signature and 1 byte of the payload.
+	if len(token) < offset+3 {
+		return f, InvalidTokenFormat
+	}
 
-		if exTime < time.Now().Unix()-c.timeBeforeExpire {
-			return nil, TokenExpired
+	f.meta = token[:offset]
+
+	var signatureLen, signatureSize int
+	if f.varint {
+		length, n := getUvarint(token[offset:])
+		if n <= 0 || length > uint64(len(token)) {
+			return f, InvalidTokenFormat
+		}
+
+		signatureLen, signatureSize = int(length), n
+	} else {
+		if token[offset] == 255 && len(token) < offset+6 {
+			return f, InvalidTokenFormat
 		}
 
-		payloadOffset = 8
+		signatureLen, signatureSize = getLenAndSize(token[offset:])
 	}
-	signatureLen, signatureSize := getLenAndSize(token[payloadOffset:])
-	signatureOffset := payloadOffset + signatureSize
-	payloadOffset += signatureSize + signatureLen
+
+	signatureOffset := offset + signatureSize
+	payloadOffset := signatureOffset + signatureLen
 
 	if len(token) <= payloadOffset {
-		return nil, InvalidTokenFormat
+		return f, InvalidTokenFormat
 	}
 
-	expectedSignature := token[signatureOffset:payloadOffset]
-	payload := token[payloadOffset:]
+	f.signature = token[signatureOffset:payloadOffset]
+	f.payload = token[payloadOffset:]
 
-	mac := c.hmacPool.Get().(hash.Hash)
-	mac.Reset()
-	mac.Write(payload)
+	return f, nil
+}
 
-	if isWithExpirationTime {
-		mac.Write(token[:8])
+// isExpired reports whether the token issued at the issuedAt is expired at the now.
+func (c *Converter) isExpired(issuedAt time.Time, timeEncoding byte, now time.Time) bool {
+	if timeEncoding == timeEncodingUnixSeconds {
+		return issuedAt.Unix() < now.Unix()-c.timeBeforeExpire
 	}
 
-	if c.postfix != nil {
-		mac.Write(c.postfix)
+	return issuedAt.Add(c.expirationTime).Before(now)
+}
+
+// expiresAt returns the expiration time of the token issued at the issuedAt.
+func (c *Converter) expiresAt(issuedAt time.Time, timeEncoding byte) time.Time {
+	if timeEncoding == timeEncodingUnixSeconds {
+		return issuedAt.Add(time.Duration(c.timeBeforeExpire) * time.Second)
 	}
 
-	actualSignature := mac.Sum(nil)
-	c.hmacPool.Put(mac)
+	return issuedAt.Add(c.expirationTime)
+}
 
-	if !hmac.Equal(expectedSignature, actualSignature) {
-		return nil, InvalidSignature
+// verify reports whether the signature of the frame is made with the key.
+func (c *Converter) verify(key *Key, f *frame) bool {
+	mac := key.algorithmMac(f.algorithm)
+	if mac == nil || key.isClosed() || len(f.signature) != c.sizeOfSignature(mac) {
+		return false
 	}
 
-	return payload, nil
+	return mac.verify(f.signature, f.payload, f.meta, c.postfix)
 }
 
-// SecretKey returns the secret key used by the Converter.
+// sizeOfSignature returns the size of the signatures made by the mac, taking into account the truncation.
+// The Ed25519 signatures are never truncated.
+func (c *Converter) sizeOfSignature(mac mac) int {
+	if _, ok := mac.(*ed25519Mac); ok {
+		return mac.Size()
+	}
+
+	if c.signatureSize != 0 && c.signatureSize < mac.Size() {
+		return c.signatureSize
+	}
+
+	return mac.Size()
+}
+
+// SecretKey returns the copy of the secret key used by the Converter.
+// If the Converter uses a KeySource, it returns the secret of the current signing key.
+// If the Converter uses a RemoteSigner, has no secret signing key or is closed, it returns nil.
+//
+// Deprecated: the returned copy is not in the guarded memory and is not zeroed by Close.
+// Keep the secret outside the Converter if it is needed. SecretKey will be removed.
 func (c *Converter) SecretKey() []byte {
-	return c.secretKey
+	key := c.signingKey()
+	if c.signer != nil || key == nil || key.isClosed() {
+		return nil
+	}
+
+	return append([]byte(nil), key.secret...)
+}
+
+// Close zeroes the key that the Converter created from the ConverterConfig.SecretKey, see Key.Close.
+// The Converter can not create and parse tokens after Close: NewTokenContext returns KeyClosed
+// and ParseToken returns KeyClosed. Close must be called after the operations with the Converter finish.
+//
+// The keys of the KeySource are not closed, because they can be shared, close them with Key.Close.
+func (c *Converter) Close() {
+	if c.key != nil {
+		c.key.Close()
+	}
 }
 
 // Postfix returns the postfix used by the Converter.
@@ -225,7 +732,11 @@ func (c *Converter) Postfix() []byte {
 	return c.postfix
 }
 
-// ExpirationTime returns the expiration time used by the Converter.
+// ExpirationTime returns the expiration time used by the Converter as it is set in the ConverterConfig.
+//
+// Breaking change: the versions before the TimeResolution returned the number of whole seconds
+// as a time.Duration, like 300ns for 5 minutes. The callers that multiplied the result by time.Second
+// must use it as is now.
 func (c *Converter) ExpirationTime() time.Duration {
-	return time.Duration(c.timeBeforeExpire)
+	return c.expirationTime
 }
@@ -3,6 +3,7 @@ package fst
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"testing"
 	"time"
@@ -267,3 +268,180 @@ func TestConverter_SecuredTestWithExpire(t *testing.T) {
 		}
 	}
 }
+
+func TestConverter_TruncatedSignature(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute * 5,
+		SignatureSize:  16,
+	})
+
+	testWithSize(t, converter, 5)
+	testWithSize(t, converter, 100000)
+
+	token := converter.NewToken([]byte(`token`))
+	if len(token) != 8+1+16+len(`token`) {
+		t.Fatal("signature is not truncated, token length is ", len(token))
+	}
+
+	// The full signature is not accepted, because it allows to use a shorter prefix.
+	fullConverter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute * 5,
+	})
+	if _, err := converter.ParseToken(fullConverter.NewToken([]byte(`token`))); !errors.Is(err, InvalidSignature) {
+		t.Fatal("full signature parse err: ", err)
+	}
+
+	shortened := append([]byte{}, token[:8]...)
+	shortened = append(shortened, 1)
+	shortened = append(shortened, token[9])
+	shortened = append(shortened, []byte(`token`)...)
+	if _, err := converter.ParseToken(shortened); !errors.Is(err, InvalidSignature) {
+		t.Fatal("shortened signature parse err: ", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("too short signature size is accepted")
+			}
+		}()
+
+		NewConverter(&ConverterConfig{
+			SecretKey:     []byte(`secret`),
+			SignatureSize: 8,
+		})
+	}()
+}
+
+func TestConverter_ExpirationTime(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute * 5,
+	})
+
+	// The Unix seconds timestamp must not change the result to the number of seconds.
+	if converter.ExpirationTime() != time.Minute*5 {
+		t.Fatal("unexpected expiration time: ", converter.ExpirationTime())
+	}
+}
+
+func TestConverter_TimeResolution(t *testing.T) {
+	for _, resolution := range []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond} {
+		converter := NewConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Millisecond * 1500,
+			TimeResolution: resolution,
+		})
+
+		testWithSize(t, converter, 5)
+		testWithSize(t, converter, 100000)
+
+		if converter.ExpirationTime() != time.Millisecond*1500 {
+			t.Fatal("unexpected expiration time: ", converter.ExpirationTime())
+		}
+
+		token := converter.NewToken([]byte(`token`))
+		// The header, at most 9 bytes of the timestamp, the signature length, the signature and the payload.
+		if len(token) > 1+9+1+32+len(`token`) {
+			t.Fatal(resolution, ": timestamp is not compact, token length is ", len(token))
+		}
+	}
+
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Millisecond * 200,
+		TimeResolution: time.Millisecond,
+	})
+
+	token := converter.NewToken([]byte(`token`))
+	if _, err := converter.ParseToken(token); err != nil {
+		t.Fatal("token parse err: ", err)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	if _, err := converter.ParseToken(token); !errors.Is(err, TokenExpired) {
+		t.Fatal("sub-second token is not expired: ", err)
+	}
+
+	// The time resolution is recorded in the header, so the token with the other resolution is parsed too.
+	secondsConverter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute,
+		TimeResolution: time.Second,
+	})
+	if _, err := secondsConverter.ParseToken(token); err != nil {
+		t.Fatal("millisecond token parse err: ", err)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	now := time.Now()
+
+	for encoding := byte(0); encoding < timeEncodingCount; encoding++ {
+		buf := appendTimestamp(nil, encoding, now)
+
+		actual, n := readTimestamp(buf, encoding)
+		if n != len(buf) {
+			t.Fatal(encoding, ": read ", n, " bytes of ", len(buf))
+		}
+
+		if now.Sub(actual) < 0 || now.Sub(actual) >= time.Duration(timeEncodingUnits[encoding]) {
+			t.Fatal(encoding, ": ", actual, " != ", now)
+		}
+	}
+
+	if _, n := readTimestamp([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, timeEncodingSeconds); n > 0 {
+		t.Fatal("overflowed timestamp is accepted")
+	}
+}
+
+func TestConverter_VarintFraming(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+		Algorithm: AlgorithmBLAKE2b256,
+	})
+	key := NewKey("", []byte(`secret`), nil)
+	payload := []byte(`token`)
+
+	sign := func(header byte, length []byte) []byte {
+		meta := []byte{header}
+		signature := key.algorithmMac(AlgorithmBLAKE2b256).sum(nil, payload, meta, nil)
+
+		token := append(meta, length...)
+		token = append(token, signature...)
+		return append(token, payload...)
+	}
+
+	// The token with the header, but without the varint framing flag, uses the old length encoding.
+	oldToken := sign(byte(AlgorithmBLAKE2b256), appendLen(nil, 32))
+	if _, err := converter.ParseToken(oldToken); err != nil {
+		t.Fatal("token with the old framing parse err: ", err)
+	}
+
+	nonMinimalToken := sign(byte(AlgorithmBLAKE2b256)|headerVarintFlag, []byte{0xa0, 0x00})
+	if _, err := converter.ParseToken(nonMinimalToken); !errors.Is(err, InvalidTokenFormat) {
+		t.Fatal("non-minimal length parse err: ", err)
+	}
+
+	minimalToken := sign(byte(AlgorithmBLAKE2b256)|headerVarintFlag, []byte{0x20})
+	if _, err := converter.ParseToken(minimalToken); err != nil {
+		t.Fatal("token with the varint framing parse err: ", err)
+	}
+
+	hugeLengthToken := sign(byte(AlgorithmBLAKE2b256)|headerVarintFlag, binary.AppendUvarint(nil, 1<<40))
+	if _, err := converter.ParseToken(hugeLengthToken); !errors.Is(err, InvalidTokenFormat) {
+		t.Fatal("huge length parse err: ", err)
+	}
+
+	buf := make([]byte, 0, 16)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = appendLen(buf[:0], 100000)
+		buf = binary.AppendUvarint(buf, 100000)
+	})
+	if allocs != 0 {
+		t.Fatal("length encoder allocates: ", allocs)
+	}
+}
@@ -0,0 +1,278 @@
+package fst
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+)
+
+var (
+	// InvalidCSRFToken means that the CSRF token is missing, malformed or is not made for the session and the scope.
+	InvalidCSRFToken = errors.New("fst: invalid csrf token")
+	// NoSession means that the request has no session cookie, so its CSRF token can not be checked.
+	NoSession = errors.New("fst: no session")
+	// CSRFNotSupported means that the CSRF tokens can not be made, because the Converter uses a RemoteSigner.
+	CSRFNotSupported = errors.New("fst: csrf tokens are not supported with a RemoteSigner")
+)
+
+// CSRF token layout (before base64):
+// [N bytes mask] [N bytes mask ^ signature]
+//
+// The signature is the MAC of session || scope || 4 bytes big-endian len(scope) || postfix with the CSRF key derived
+// from the secret of the session Converter. The random mask makes every token unique, so the token in the page
+// can not be guessed by the compression side channels like BREACH.
+
+// CSRF represents the protection from the cross-site request forgery for the requests authenticated by a session cookie.
+// Its tokens are bound to the session token and to the scope, like a form or an action,
+// so they don't need to be stored: Check and Token parse the session token with the Converter,
+// so a token is valid while its session is valid.
+//
+// # Example:
+//
+//	csrf := fst.NewCSRF(&fst.CSRFConfig{
+//		Converter:     sessionConverter,
+//		SessionCookie: "session",
+//	})
+//
+//	// In the form handler:
+//	token, err := csrf.Token(r, "")
+//	// <input type="hidden" name="csrf_token" value="{{ token }}">
+//
+//	http.Handle("/", csrf.Handler(mux))
+type CSRF struct {
+	sessions      *EncodedConverter
+	converter     *Converter
+	sessionCookie string
+	headerName    string
+	formField     string
+	scope         func(r *http.Request) string
+	onError       func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// CSRFConfig represents the configuration options for creating a new CSRF.
+//
+// Converter is the EncodedConverter of the session tokens.
+//
+// SessionCookie is the name of the cookie with the session token. It is "session" by default.
+//
+// HeaderName is the name of the header with the CSRF token. It is "X-CSRF-Token" by default.
+//
+// FormField is the name of the form field with the CSRF token. It is "csrf_token" by default.
+//
+// Scope returns the scope of the request. It is nil by default and all tokens have the empty scope.
+//
+// OnError is called by the Handler when the request is rejected. It responds with 403 Forbidden by default.
+type CSRFConfig struct {
+	// Converter is the EncodedConverter of the session tokens. The CSRF tokens are signed with the keys derived
+	// from its keys, so they are rotated together with the session keys.
+	Converter *EncodedConverter
+	// SessionCookie is the name of the cookie with the session token. It is "session" by default.
+	SessionCookie string
+	// HeaderName is the name of the header with the CSRF token. It is checked before the FormField.
+	// It is "X-CSRF-Token" by default.
+	HeaderName string
+	// FormField is the name of the form field with the CSRF token. It is "csrf_token" by default.
+	FormField string
+	// Scope returns the scope of the request, like the path of the form action, so a token of one form
+	// can not be used to submit another one. The tokens must be created by Token with the same scope.
+	// It is nil by default and all tokens have the empty scope.
+	Scope func(r *http.Request) string
+	// OnError is called by the Handler when the request is rejected. It responds with 403 Forbidden by default.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewCSRF creates a new instance of the CSRF based on the provided fst.CSRFConfig.
+//
+// An example of usage can be found at CSRF.
+func NewCSRF(cfg *CSRFConfig) *CSRF {
+	if cfg.Converter == nil {
+		panic("fst: CSRFConfig.Converter is nil")
+	}
+
+	csrf := &CSRF{
+		sessions:      cfg.Converter,
+		converter:     cfg.Converter.converter,
+		sessionCookie: cfg.SessionCookie,
+		headerName:    cfg.HeaderName,
+		formField:     cfg.FormField,
+		scope:         cfg.Scope,
+		onError:       cfg.OnError,
+	}
+
+	if csrf.sessionCookie == "" {
+		csrf.sessionCookie = "session"
+	}
+
+	if csrf.headerName == "" {
+		csrf.headerName = "X-CSRF-Token"
+	}
+
+	if csrf.formField == "" {
+		csrf.formField = "csrf_token"
+	}
+
+	if csrf.scope == nil {
+		csrf.scope = func(*http.Request) string { return "" }
+	}
+
+	if csrf.onError == nil {
+		csrf.onError = func(w http.ResponseWriter, _ *http.Request, _ error) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}
+
+	return csrf
+}
+
+// NewToken creates a new CSRF token for the session token and the scope. Every call returns a different token,
+// but all of them are valid for the session and the scope.
+//
+// It returns CSRFNotSupported if the Converter uses a RemoteSigner, SymmetricKeyRequired if it uses AlgorithmEd25519
+// or KeyClosed if its key is closed.
+func (c *CSRF) NewToken(session, scope string) (string, error) {
+	if c.converter.signer != nil {
+		return "", CSRFNotSupported
+	}
+
+	key, mac, err := c.converter.derivedSigningMac(purposeCSRF)
+	if err != nil {
+		return "", err
+	}
+
+	signature := c.sum(mac, session, scope)
+	if key.isClosed() {
+		return "", KeyClosed
+	}
+
+	token := make([]byte, 2*len(signature))
+	if _, err := rand.Read(token[:len(signature)]); err != nil {
+		return "", err
+	}
+
+	for i := range signature {
+		token[len(signature)+i] = token[i] ^ signature[i]
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+// Token creates a new CSRF token for the session of the r and the scope.
+//
+// It can return NoSession, the error of parsing the session token, like TokenExpired, or CSRFNotSupported.
+func (c *CSRF) Token(r *http.Request, scope string) (string, error) {
+	session, err := c.session(r)
+	if err != nil {
+		return "", err
+	}
+
+	return c.NewToken(session, scope)
+}
+
+// session returns the session token of the r after it is parsed by the Converter.
+func (c *CSRF) session(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(c.sessionCookie)
+	if err != nil {
+		return "", NoSession
+	}
+
+	if _, err = c.sessions.ParseTokenContext(r.Context(), cookie.Value); err != nil {
+		return "", err
+	}
+
+	return cookie.Value, nil
+}
+
+// Verify checks that the token is made for the session token and the scope.
+// It does not parse the session token, so the caller must check that the session is valid.
+//
+// It can return InvalidCSRFToken, CSRFNotSupported or KeyClosed if the token is not verified and the key is closed.
+func (c *CSRF) Verify(session, scope, token string) error {
+	if c.converter.signer != nil {
+		return CSRFNotSupported
+	}
+
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil || len(decodedToken) == 0 || len(decodedToken)%2 != 0 {
+		return InvalidCSRFToken
+	}
+
+	size := len(decodedToken) / 2
+	signature := decodedToken[size:]
+	for i := range signature {
+		signature[i] ^= decodedToken[i]
+	}
+
+	keys := []*Key{c.converter.key}
+	if c.converter.keySource != nil {
+		keys = c.converter.keySource.VerificationKeys()
+	}
+
+	closed := false
+	for _, key := range keys {
+		mac := key.derivedMac(purposeCSRF, c.converter.algorithm)
+		// The sums made during Close have the zeroed key, so they are rejected by the check after the sum.
+		if mac != nil && c.converter.sizeOfSignature(mac) == size && equalPrefix(signature, c.sum(mac, session, scope)) && !key.isClosed() {
+			return nil
+		}
+		closed = closed || key.isClosed()
+	}
+
+	if closed {
+		return KeyClosed
+	}
+
+	return InvalidCSRFToken
+}
+
+// sum returns the truncated signature of the session and the scope.
+func (c *CSRF) sum(mac mac, session, scope string) []byte {
+	meta := make([]byte, 0, len(scope)+4)
+	meta = append(meta, scope...)
+	meta = binary.BigEndian.AppendUint32(meta, uint32(len(scope)))
+
+	signature := mac.sum(make([]byte, 0, mac.Size()), []byte(session), meta, c.converter.postfix)
+
+	return signature[:c.converter.sizeOfSignature(mac)]
+}
+
+// Check checks the CSRF token of the r with the unsafe method. The requests with the safe methods
+// (GET, HEAD, OPTIONS, TRACE) are always accepted, so they must not change anything.
+//
+// The session token is parsed by the Converter, so the requests with an expired or a forged session are rejected.
+//
+// It can return NoSession, the error of parsing the session token, like TokenExpired or InvalidSignature,
+// InvalidCSRFToken or CSRFNotSupported.
+func (c *CSRF) Check(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	session, err := c.session(r)
+	if err != nil {
+		return err
+	}
+
+	token := r.Header.Get(c.headerName)
+	if token == "" {
+		token = r.PostFormValue(c.formField)
+	}
+
+	return c.Verify(session, c.scope(r), token)
+}
+
+// Handler returns the http.Handler that calls the next only for the requests that pass Check.
+// Other requests are passed to the OnError. The unsafe requests without the session are rejected too,
+// so don't wrap the handlers that create the session, like the login form.
+func (c *CSRF) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Check(r); err != nil {
+			c.onError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,151 @@
+package fst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSRF(t *testing.T) {
+	sessionConverter := NewEncodedConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+	csrf := NewCSRF(&CSRFConfig{
+		Converter: sessionConverter,
+	})
+
+	session := sessionConverter.NewToken([]byte(`user 1`))
+	otherSession := sessionConverter.NewToken([]byte(`user 2`))
+
+	token, err := csrf.NewToken(session, "transfer")
+	if err != nil {
+		t.Fatal("NewToken err: ", err)
+	}
+
+	if err = csrf.Verify(session, "transfer", token); err != nil {
+		t.Fatal("Verify err: ", err)
+	}
+
+	if another, _ := csrf.NewToken(session, "transfer"); another == token {
+		t.Fatal("CSRF tokens are not masked")
+	}
+
+	for _, test := range []struct {
+		session, scope, token string
+	}{
+		{otherSession, "transfer", token},
+		{session, "delete", token},
+		{session, "transfe", token},
+		{session, "transfer", ""},
+		{session, "transfer", "bm90IGEgdG9rZW4="},
+	} {
+		if err = csrf.Verify(test.session, test.scope, test.token); !errors.Is(err, InvalidCSRFToken) {
+			t.Error("Verify(", test.scope, ", ", test.token, ") err: ", err)
+		}
+	}
+}
+
+func TestCSRF_Handler(t *testing.T) {
+	sessionConverter := NewEncodedConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+	csrf := NewCSRF(&CSRFConfig{
+		Converter: sessionConverter,
+		Scope: func(r *http.Request) string {
+			return r.URL.Path
+		},
+	})
+	handler := csrf.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cookie := &http.Cookie{Name: "session", Value: sessionConverter.NewToken([]byte(`user`))}
+	formRequest := httptest.NewRequest(http.MethodGet, "/form", nil)
+	formRequest.AddCookie(cookie)
+	token, err := csrf.Token(formRequest, "/transfer")
+	if err != nil {
+		t.Fatal("Token err: ", err)
+	}
+
+	newRequest := func(method, path, header, form string, withCookie bool) *http.Request {
+		r := httptest.NewRequest(method, path, strings.NewReader(url.Values{"csrf_token": {form}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set("X-CSRF-Token", header)
+		}
+		if withCookie {
+			r.AddCookie(cookie)
+		}
+
+		return r
+	}
+
+	for name, test := range map[string]struct {
+		r      *http.Request
+		status int
+	}{
+		"safe method":     {newRequest(http.MethodGet, "/transfer", "", "", false), http.StatusNoContent},
+		"form field":      {newRequest(http.MethodPost, "/transfer", "", token, true), http.StatusNoContent},
+		"header":          {newRequest(http.MethodPost, "/transfer", token, "", true), http.StatusNoContent},
+		"no token":        {newRequest(http.MethodPost, "/transfer", "", "", true), http.StatusForbidden},
+		"no session":      {newRequest(http.MethodPost, "/transfer", token, "", false), http.StatusForbidden},
+		"different scope": {newRequest(http.MethodPost, "/delete", token, "", true), http.StatusForbidden},
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, test.r)
+		if recorder.Code != test.status {
+			t.Error(name, ": status is ", recorder.Code)
+		}
+	}
+}
+
+func TestCSRF_CheckSession(t *testing.T) {
+	now := time.Now()
+	sessionConverter := NewEncodedConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute,
+		Now: func() time.Time {
+			return now
+		},
+	})
+	csrf := NewCSRF(&CSRFConfig{
+		Converter: sessionConverter,
+	})
+
+	newRequest := func(session string) *http.Request {
+		token, err := csrf.NewToken(session, "")
+		if err != nil {
+			t.Fatal("NewToken err: ", err)
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/transfer", nil)
+		r.Header.Set("X-CSRF-Token", token)
+		r.AddCookie(&http.Cookie{Name: "session", Value: session})
+
+		return r
+	}
+
+	session := sessionConverter.NewToken([]byte(`user`))
+	if err := csrf.Check(newRequest(session)); err != nil {
+		t.Fatal("Check err: ", err)
+	}
+
+	forged := NewEncodedConverter(&ConverterConfig{SecretKey: []byte(`another secret`), ExpirationTime: time.Minute}).
+		NewToken([]byte(`admin`))
+	if err := csrf.Check(newRequest(forged)); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Check of a forged session err: ", err)
+	}
+
+	expired := newRequest(session)
+	now = now.Add(time.Minute * 2)
+	if err := csrf.Check(expired); !errors.Is(err, TokenExpired) {
+		t.Fatal("Check of an expired session err: ", err)
+	}
+	if _, err := csrf.Token(expired, ""); !errors.Is(err, TokenExpired) {
+		t.Fatal("Token of an expired session err: ", err)
+	}
+}
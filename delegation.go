@@ -0,0 +1,208 @@
+package fst
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+)
+
+// DelegationTooDeep means that the delegation chain has more actors than the MaxDepth.
+var DelegationTooDeep = errors.New("fst: delegation chain is too deep")
+
+// DefaultMaxDelegationDepth is the default maximum number of the actors in the delegation chain.
+const DefaultMaxDelegationDepth = 4
+
+// Delegated token layout:
+// [1 byte depth] [Registry token of the actor]
+//
+// The value of the actor's token is [1 byte depth] [uvarint len] [actor value] [inner token], where the inner token
+// is the subject token if the depth is 1, otherwise the delegated token of the depth - 1.
+// The depth is repeated in the signed value, so it can not be changed, and the actor ID is bound to the signature by the Registry.
+
+// Actor is the link of the delegation chain: the ID of the service that acts on behalf of the subject and its value.
+type Actor struct {
+	ID    string
+	Value []byte
+}
+
+// DelegationChain is the verified delegation chain.
+type DelegationChain struct {
+	// Subject is the value of the subject token, like the user.
+	Subject []byte
+	// Actors are the actors of the chain. Actors[0] is the actor that made the token,
+	// the last one is the actor that received the subject token, like the nested act claims of OAuth 2.0 Token Exchange.
+	Actors []Actor
+}
+
+// Delegation represents the delegation chains: a service that calls another service on behalf of the subject wraps
+// the subject token into its own actor token, so the called service verifies both who calls it and for whom.
+// The called service can delegate the token further, up to the MaxDepth actors.
+//
+// The actors should sign with the Ed25519 keys, so the services can verify the actor tokens, but can not forge them.
+//
+// # Example:
+//
+//	delegation := fst.NewDelegation(&fst.DelegationConfig{
+//		Subject: userConverter,
+//		Actors: fst.NewRegistry(&fst.RegistryConfig{
+//			Provider: actorKeys, // the ConverterConfigs of the services
+//		}),
+//	})
+//
+//	// In the service A:
+//	token, err := delegation.Delegate("service-a", []byte(`request 1`), userToken)
+//
+//	// In the service B:
+//	chain, err := delegation.ParseToken(token)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(chain.Actors[0].ID, string(chain.Subject)) // service-a user 1
+type Delegation struct {
+	subject  *Converter
+	actors   *Registry
+	maxDepth int
+}
+
+// DelegationConfig represents the configuration options for creating a new Delegation.
+//
+// Subject is the Converter of the subject tokens.
+//
+// Actors is the Registry of the actors' Converters.
+//
+// MaxDepth is the maximum number of the actors in the chain. It is DefaultMaxDelegationDepth by default.
+type DelegationConfig struct {
+	// Subject is the Converter of the subject tokens, like the user tokens.
+	Subject *Converter
+	// Actors is the Registry of the actors' Converters, where the tenant ID is the actor ID.
+	// The service can delegate only as the actor whose Converter can sign tokens.
+	Actors *Registry
+	// MaxDepth is the maximum number of the actors in the chain. It is DefaultMaxDelegationDepth by default
+	// and can not be greater than 255.
+	MaxDepth int
+}
+
+// NewDelegation creates a new instance of the Delegation based on the provided fst.DelegationConfig.
+//
+// An example of usage can be found at Delegation.
+func NewDelegation(cfg *DelegationConfig) *Delegation {
+	if cfg.Subject == nil || cfg.Actors == nil {
+		panic("fst: DelegationConfig.Subject and DelegationConfig.Actors must be set")
+	}
+
+	if cfg.MaxDepth > 255 {
+		panic("fst: DelegationConfig.MaxDepth can not be greater than 255")
+	}
+
+	d := &Delegation{
+		subject:  cfg.Subject,
+		actors:   cfg.Actors,
+		maxDepth: cfg.MaxDepth,
+	}
+
+	if d.maxDepth <= 0 {
+		d.maxDepth = DefaultMaxDelegationDepth
+	}
+
+	return d
+}
+
+// Delegate verifies the subject token and wraps it into the token of the actor with the actorValue,
+// like the request ID or the reason of the call.
+//
+// It can return the errors of the subject token parsing, like InvalidSignature or TokenExpired,
+// or the errors of the Registry, like UnknownTenant.
+func (d *Delegation) Delegate(actorID string, actorValue, subjectToken []byte) ([]byte, error) {
+	if _, err := d.subject.ParseToken(subjectToken); err != nil {
+		return nil, err
+	}
+
+	return d.wrap(1, actorID, actorValue, subjectToken)
+}
+
+// Redelegate verifies the delegated token and wraps it into the token of the actor, like Delegate,
+// so the chain gets one more actor.
+//
+// It can also return DelegationTooDeep.
+func (d *Delegation) Redelegate(actorID string, actorValue, delegatedToken []byte) ([]byte, error) {
+	chain, err := d.ParseToken(delegatedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain.Actors) >= d.maxDepth {
+		return nil, DelegationTooDeep
+	}
+
+	return d.wrap(len(chain.Actors)+1, actorID, actorValue, delegatedToken)
+}
+
+func (d *Delegation) wrap(depth int, actorID string, actorValue, inner []byte) ([]byte, error) {
+	value := make([]byte, 0, 1+getSizeForUvarint(uint64(len(actorValue)))+len(actorValue)+len(inner))
+	value = append(value, byte(depth))
+	value = binary.AppendUvarint(value, uint64(len(actorValue)))
+	value = append(value, actorValue...)
+	value = append(value, inner...)
+
+	token, err := d.actors.NewToken(actorID, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{byte(depth)}, token...), nil
+}
+
+// ParseToken verifies all tokens of the chain and returns it. This method will not copy the values.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, DelegationTooDeep
+// or the errors of the Registry, like UnknownTenant.
+func (d *Delegation) ParseToken(token []byte) (DelegationChain, error) {
+	return d.ParseTokenContext(context.Background(), token)
+}
+
+// ParseTokenContext verifies the chain like ParseToken, but passes the ctx to the RemoteSigner of the subject Converter.
+func (d *Delegation) ParseTokenContext(ctx context.Context, token []byte) (DelegationChain, error) {
+	var chain DelegationChain
+
+	if len(token) == 0 || token[0] == 0 {
+		return chain, InvalidTokenFormat
+	}
+
+	if int(token[0]) > d.maxDepth {
+		return chain, DelegationTooDeep
+	}
+
+	chain.Actors = make([]Actor, 0, token[0])
+
+	for depth := int(token[0]); depth > 0; depth-- {
+		if len(token) == 0 || int(token[0]) != depth {
+			return chain, InvalidTokenFormat
+		}
+
+		actorID, value, err := d.actors.ParseToken(token[1:])
+		if err != nil {
+			return chain, err
+		}
+
+		if len(value) == 0 || int(value[0]) != depth {
+			return chain, InvalidTokenFormat
+		}
+
+		actorValueLen, size := getUvarint(value[1:])
+		if size <= 0 || actorValueLen > uint64(len(value)-1-size) {
+			return chain, InvalidTokenFormat
+		}
+		offset := 1 + size
+
+		chain.Actors = append(chain.Actors, Actor{ID: actorID, Value: value[offset : offset+int(actorValueLen)]})
+		token = value[offset+int(actorValueLen):]
+	}
+
+	subject, err := d.subject.ParseTokenContext(ctx, token)
+	if err != nil {
+		return chain, err
+	}
+	chain.Subject = subject
+
+	return chain, nil
+}
@@ -0,0 +1,85 @@
+package fst
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelegation(t *testing.T) {
+	actorKeys := map[string]*Key{
+		"gateway": newTestEd25519Key(t, "gateway"),
+		"orders":  newTestEd25519Key(t, "orders"),
+		"billing": newTestEd25519Key(t, "billing"),
+	}
+
+	actors := NewRegistry(&RegistryConfig{
+		Provider: TenantConfigProviderFunc(func(actorID string) (*ConverterConfig, error) {
+			key, ok := actorKeys[actorID]
+			if !ok {
+				return nil, UnknownTenant
+			}
+
+			return &ConverterConfig{
+				Algorithm:      AlgorithmEd25519,
+				KeySource:      NewStaticKeySource(key),
+				ExpirationTime: time.Minute,
+			}, nil
+		}),
+	})
+
+	users := NewConverter(&ConverterConfig{SecretKey: []byte(`secret`), ExpirationTime: time.Hour})
+	delegation := NewDelegation(&DelegationConfig{
+		Subject:  users,
+		Actors:   actors,
+		MaxDepth: 2,
+	})
+
+	userToken := users.NewToken([]byte(`user 1`))
+
+	token, err := delegation.Delegate("gateway", []byte(`request 1`), userToken)
+	if err != nil {
+		t.Fatal("Delegate err: ", err)
+	}
+
+	token, err = delegation.Redelegate("orders", nil, token)
+	if err != nil {
+		t.Fatal("Redelegate err: ", err)
+	}
+
+	chain, err := delegation.ParseToken(token)
+	if err != nil {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	if string(chain.Subject) != "user 1" || len(chain.Actors) != 2 ||
+		chain.Actors[0].ID != "orders" || chain.Actors[1].ID != "gateway" || string(chain.Actors[1].Value) != "request 1" {
+		t.Fatal("Chain is ", chain)
+	}
+
+	if _, err = delegation.Redelegate("billing", nil, token); !errors.Is(err, DelegationTooDeep) {
+		t.Fatal("Too deep Redelegate err: ", err)
+	}
+
+	if _, err = delegation.Delegate("gateway", nil, userToken[:len(userToken)-1]); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Delegate of invalid token err: ", err)
+	}
+
+	// The outer depth is changed, so the inner token looks like the subject token.
+	forged := append([]byte{1}, token[1:]...)
+	if _, err = delegation.ParseToken(forged); err == nil {
+		t.Fatal("Token with forged depth is parsed")
+	}
+
+	// The inner token is changed.
+	forged = append([]byte(nil), token...)
+	forged[len(forged)-1] ^= 1
+	if _, err = delegation.ParseToken(forged); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Token with forged subject parse err: ", err)
+	}
+
+	// The actor is unknown.
+	if _, err = delegation.Delegate("unknown", nil, userToken); !errors.Is(err, UnknownTenant) {
+		t.Fatal("Delegate by unknown actor err: ", err)
+	}
+}
@@ -0,0 +1,104 @@
+package fst
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+var (
+	// VerificationOnly means that the Converter can not sign tokens, because its signing key has only the public key
+	// or its KeySource has no signing key, like KeySet.
+	VerificationOnly = errors.New("fst: the key can only verify tokens")
+	// SymmetricKeyRequired means that the feature, like segmented tokens, streams or CSRF tokens,
+	// needs a secret key and does not work with the Ed25519 keys.
+	SymmetricKeyRequired = errors.New("fst: the feature requires a symmetric key")
+)
+
+// ed25519Mac signs the payload || meta || postfix with Ed25519. It is not a MAC, but it has the same interface,
+// so the Ed25519 tokens have the same layout as other tokens. The signatures are always 64 bytes and are never truncated.
+type ed25519Mac struct {
+	// privateKey is nil for the verification keys. It is in the Go heap, because crypto/ed25519 caches
+	// the expanded keys by the weak pointers, that can not point to the guarded memory.
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// Size returns the size of the signature.
+func (m *ed25519Mac) Size() int {
+	return ed25519.SignatureSize
+}
+
+func (m *ed25519Mac) sum(dst, payload, meta, postfix []byte) []byte {
+	return append(dst, ed25519.Sign(m.privateKey, concatMessage(payload, meta, postfix))...)
+}
+
+func (m *ed25519Mac) verify(signature, payload, meta, postfix []byte) bool {
+	return len(signature) == ed25519.SignatureSize && ed25519.Verify(m.publicKey, concatMessage(payload, meta, postfix), signature)
+}
+
+// acquire returns the mac itself, because it has no pooled state.
+func (m *ed25519Mac) acquire() macSession {
+	return m
+}
+
+func (m *ed25519Mac) release() {}
+
+// zero zeroes the private key, but keeps the slice, because the running operations can read it.
+// The expanded key cached by crypto/ed25519 is released by the GC.
+func (m *ed25519Mac) zero() {
+	clear(m.privateKey)
+}
+
+func concatMessage(payload, meta, postfix []byte) []byte {
+	message := make([]byte, 0, len(payload)+len(meta)+len(postfix))
+	message = append(message, payload...)
+	message = append(message, meta...)
+
+	return append(message, postfix...)
+}
+
+// NewEd25519Key creates a new Key with the Ed25519 private key. It signs and verifies the tokens of AlgorithmEd25519.
+// The verifiers need only its public key, see NewEd25519VerificationKey and JWKSHandler.
+//
+// The Ed25519 keys can not be used for the features that need a secret: segmented tokens, streams and CSRF tokens.
+// The private key is copied and the copy is zeroed by Key.Close, but it is not in the guarded memory.
+func NewEd25519Key(id string, privateKey ed25519.PrivateKey) *Key {
+	key := NewEd25519VerificationKey(id, privateKey.Public().(ed25519.PublicKey))
+	key.macs[AlgorithmEd25519].(*ed25519Mac).privateKey = append(ed25519.PrivateKey(nil), privateKey...)
+
+	return key
+}
+
+// NewEd25519VerificationKey creates a new Key with the Ed25519 public key. It only verifies the tokens of AlgorithmEd25519.
+func NewEd25519VerificationKey(id string, publicKey ed25519.PublicKey) *Key {
+	key := &Key{
+		ID:         id,
+		asymmetric: true,
+	}
+	key.macs[AlgorithmEd25519] = &ed25519Mac{publicKey: publicKey}
+
+	return key
+}
+
+// PublicKey returns the Ed25519 public key of the Key or nil if the Key is symmetric.
+func (k *Key) PublicKey() ed25519.PublicKey {
+	if m, ok := k.macs[AlgorithmEd25519].(*ed25519Mac); ok {
+		return m.publicKey
+	}
+
+	return nil
+}
+
+// signingMac returns the mac of the algorithm that can sign or nil if the key can not sign with the algorithm.
+func (k *Key) signingMac(algorithm Algorithm) mac {
+	if k == nil || k.isClosed() {
+		return nil
+	}
+
+	mac := k.algorithmMac(algorithm)
+	if m, ok := mac.(*ed25519Mac); ok && m.privateKey == nil {
+		return nil
+	}
+
+	return mac
+}
@@ -1,6 +1,7 @@
 package fst
 
 import (
+	"context"
 	"encoding/base64"
 )
 
@@ -66,6 +67,17 @@ func (c *EncodedConverter) NewToken(value []byte) string {
 	return base64.URLEncoding.EncodeToString(c.converter.NewToken(value))
 }
 
+// NewTokenContext creates a new FST with the provided value like NewToken,
+// but passes the ctx to the RemoteSigner and returns its error.
+func (c *EncodedConverter) NewTokenContext(ctx context.Context, value []byte) (string, error) {
+	token, err := c.converter.NewTokenContext(ctx, value)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
 // ParseToken parses a FST and returns the value.
 // This method will copy the token's value.
 //
@@ -78,3 +90,42 @@ func (c *EncodedConverter) ParseToken(token string) ([]byte, error) {
 
 	return c.converter.ParseToken(decodedToken)
 }
+
+// ParseTokenContext parses a FST like ParseToken, but passes the ctx to the RemoteSigner.
+func (c *EncodedConverter) ParseTokenContext(ctx context.Context, token string) ([]byte, error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.converter.ParseTokenContext(ctx, decodedToken)
+}
+
+// NewSegmentedToken creates a new segmented FST with the public and private segments like Converter.NewSegmentedToken.
+// This method encodes the token in base64. The public segment can be read with PeekPublic without the key.
+func (c *EncodedConverter) NewSegmentedToken(public, private []byte) (string, error) {
+	token, err := c.converter.NewSegmentedToken(public, private)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+// ParseSegmentedToken parses a segmented FST and returns its public and decrypted private segments.
+// This method will copy the token's segments.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, SegmentsNotSupported.
+func (c *EncodedConverter) ParseSegmentedToken(token string) (public, private []byte, err error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.converter.ParseSegmentedToken(decodedToken)
+}
+
+// Close zeroes the key of the Converter, see Converter.Close.
+func (c *EncodedConverter) Close() {
+	c.converter.Close()
+}
@@ -0,0 +1,404 @@
+package fst
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EmptyKeyFile means that the key file is empty.
+var EmptyKeyFile = errors.New("fst: key file is empty")
+
+// FileKeySourceConfig represents the configuration options for creating a new FileKeySource.
+//
+// Path is the path to a key file or to a directory of versioned keys.
+//
+// PollInterval is how often the modification time of the keys is checked. It is 10 seconds by default.
+//
+// GracePeriod is how long the replaced keys are still accepted for verification. Then they are closed.
+//
+// HashType is the hash function used to sign the token.
+//
+// OnReload is called after the keys have been reloaded.
+//
+// OnError is called when the keys can not be reloaded. The previous keys stay in use.
+type FileKeySourceConfig struct {
+	// Path is the path to a key file or to a directory of versioned keys.
+	// In the directory, every file is a key whose ID is the file name, and the key with the greatest name is used for signing.
+	// Hidden files (starting with a dot) are ignored.
+	Path string
+	// PollInterval is how often the modification time of the keys is checked.
+	PollInterval time.Duration
+	// GracePeriod is how long the replaced keys are still accepted for verification.
+	// The keys are closed by the next reload after it expires, so the operations that have started with them can finish.
+	GracePeriod time.Duration
+	// HashType is the hash function used to sign the token.
+	HashType func() hash.Hash
+	// OnReload is called after the keys have been reloaded with the ID of the new signing key.
+	OnReload func(signingKeyID string)
+	// OnError is called when the keys can not be reloaded. The previous keys stay in use.
+	OnError func(err error)
+}
+
+// FileKeySource is a KeySource that reads keys from files and reloads them when they are changed.
+// It is useful with secrets that are mounted as files and rotated by an orchestrator.
+//
+// # Example:
+//
+//	keySource, err := fst.NewFileKeySource(&fst.FileKeySourceConfig{
+//		Path:         "/etc/secrets/fst",
+//		PollInterval: time.Second * 30,
+//		GracePeriod:  time.Hour,
+//		OnError: func(err error) {
+//			log.Println(err)
+//		},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer keySource.Close()
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		KeySource: keySource,
+//	})
+type FileKeySource struct {
+	cfg FileKeySourceConfig
+
+	keys atomic.Pointer[fileKeySet]
+
+	mu          sync.Mutex
+	fingerprint string
+	retired     []retiredKey
+	// closing are the keys whose grace period has expired. They are closed by the next Reload.
+	closing []*Key
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type fileKeySet struct {
+	signing *Key
+	// active are the keys that were read from the files.
+	active       []*Key
+	verification []*Key
+}
+
+type retiredKey struct {
+	key       *Key
+	expiresAt time.Time
+}
+
+// NewFileKeySource creates a new instance of the FileKeySource based on the provided fst.FileKeySourceConfig.
+// It loads the keys and starts watching them. Call Close to stop watching.
+//
+// An example of usage can be found at FileKeySource.
+func NewFileKeySource(cfg *FileKeySourceConfig) (*FileKeySource, error) {
+	source := &FileKeySource{
+		cfg:  *cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if source.cfg.PollInterval <= 0 {
+		source.cfg.PollInterval = time.Second * 10
+	}
+
+	if err := source.Reload(); err != nil {
+		return nil, err
+	}
+
+	go source.watch()
+
+	return source, nil
+}
+
+// SigningKey returns the key used to sign new tokens.
+func (s *FileKeySource) SigningKey() *Key {
+	return s.keys.Load().signing
+}
+
+// VerificationKeys returns all keys accepted for verification: the signing key goes first,
+// then the other keys from the directory and then the replaced keys whose grace period has not expired.
+func (s *FileKeySource) VerificationKeys() []*Key {
+	return s.keys.Load().verification
+}
+
+// Reload checks the modification time of the keys and reloads them if they were changed.
+// It also drops the replaced keys whose grace period has expired and closes the keys dropped by the previous Reload.
+func (s *FileKeySource) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprint, err := s.fingerprintKeys()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	current := s.keys.Load()
+
+	if current != nil && fingerprint == s.fingerprint {
+		if s.dropExpired(now) {
+			s.keys.Store(s.buildKeySet(current.active, now))
+		}
+
+		return nil
+	}
+
+	keys, err := s.readKeys()
+	if err != nil {
+		return err
+	}
+
+	if current != nil {
+		s.reuseKeys(current, keys)
+
+		for _, old := range current.active {
+			if !containsKey(keys, old) {
+				s.retired = append(s.retired, retiredKey{key: old, expiresAt: now.Add(s.cfg.GracePeriod)})
+			}
+		}
+
+		// The retired keys that are in the files again are not retired anymore.
+		kept := s.retired[:0]
+		for _, retired := range s.retired {
+			if !containsKey(keys, retired.key) {
+				kept = append(kept, retired)
+			}
+		}
+		clear(s.retired[len(kept):])
+		s.retired = kept
+	}
+
+	s.dropExpired(now)
+	s.fingerprint = fingerprint
+	s.keys.Store(s.buildKeySet(keys, now))
+
+	if s.cfg.OnReload != nil {
+		s.cfg.OnReload(keys[0].ID)
+	}
+
+	return nil
+}
+
+// Close stops watching the keys. The FileKeySource can still be used after Close with the last loaded keys.
+// The loaded keys are not closed.
+func (s *FileKeySource) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+func (s *FileKeySource) watch() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil && s.cfg.OnError != nil {
+				s.cfg.OnError(err)
+			}
+		}
+	}
+}
+
+// dropExpired removes the retired keys whose grace period has expired and reports whether any were removed.
+// It closes the keys removed by the previous call, because some operations can still use them.
+func (s *FileKeySource) dropExpired(now time.Time) bool {
+	for _, key := range s.closing {
+		key.Close()
+	}
+	clear(s.closing)
+	s.closing = s.closing[:0]
+
+	kept := s.retired[:0]
+	for _, retired := range s.retired {
+		if now.Before(retired.expiresAt) {
+			kept = append(kept, retired)
+		} else {
+			s.closing = append(s.closing, retired.key)
+		}
+	}
+
+	dropped := len(kept) != len(s.retired)
+	for i := len(kept); i < len(s.retired); i++ {
+		s.retired[i] = retiredKey{}
+	}
+	s.retired = kept
+
+	return dropped
+}
+
+// buildKeySet creates a set of the keys read from the files and the retired keys. keys[0] is the signing key.
+func (s *FileKeySource) buildKeySet(keys []*Key, now time.Time) *fileKeySet {
+	verification := make([]*Key, 0, len(keys)+len(s.retired))
+	verification = append(verification, keys...)
+
+	for _, retired := range s.retired {
+		if now.Before(retired.expiresAt) && !containsKey(verification, retired.key) {
+			verification = append(verification, retired.key)
+		}
+	}
+
+	return &fileKeySet{
+		signing:      keys[0],
+		active:       keys,
+		verification: verification,
+	}
+}
+
+// fingerprintKeys returns a string that changes whenever any key file is changed.
+func (s *FileKeySource) fingerprintKeys() (string, error) {
+	info, err := os.Stat(s.cfg.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return fingerprintFile(info), nil
+	}
+
+	names, err := s.keyFileNames()
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	for _, name := range names {
+		info, err = os.Stat(filepath.Join(s.cfg.Path, name))
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(fingerprintFile(info))
+		b.WriteByte(';')
+	}
+
+	return b.String(), nil
+}
+
+func fingerprintFile(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + "/" + strconv.FormatInt(info.Size(), 10)
+}
+
+// keyFileNames returns the names of the key files in the directory. The greatest name goes first.
+func (s *FileKeySource) keyFileNames() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name()[0] == '.' || entry.IsDir() {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("fst: key directory " + s.cfg.Path + " has no keys")
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	return names, nil
+}
+
+// readKeys reads all keys. The signing key goes first.
+func (s *FileKeySource) readKeys() ([]*Key, error) {
+	info, err := os.Stat(s.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		key, err := s.readKey(filepath.Base(s.cfg.Path), s.cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Key{key}, nil
+	}
+
+	names, err := s.keyFileNames()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(names))
+	for _, name := range names {
+		key, err := s.readKey(name, filepath.Join(s.cfg.Path, name))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *FileKeySource) readKey(id, path string) (*Key, error) {
+	secret, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Orchestrators and editors often add a trailing newline to the secret.
+	secret = bytes.TrimRight(secret, "\r\n")
+	if len(secret) == 0 {
+		return nil, EmptyKeyFile
+	}
+
+	return NewKey(id, secret, s.cfg.HashType), nil
+}
+
+// reuseKeys replaces the read keys that are already loaded, active or retired, with the loaded ones
+// and closes the read copies, so only the keys that left the files are retired and closed.
+func (s *FileKeySource) reuseKeys(current *fileKeySet, keys []*Key) {
+	for i, key := range keys {
+		loaded := findKey(current.active, key)
+		for j := 0; loaded == nil && j < len(s.retired); j++ {
+			loaded = findKey([]*Key{s.retired[j].key}, key)
+		}
+
+		if loaded != nil {
+			key.Close()
+			keys[i] = loaded
+		}
+	}
+}
+
+// containsKey reports whether keys contain a key with the same ID and secret as the key.
+func containsKey(keys []*Key, key *Key) bool {
+	return findKey(keys, key) != nil
+}
+
+// findKey returns the key of the keys with the same ID and secret as the key or nil.
+func findKey(keys []*Key, key *Key) *Key {
+	for _, k := range keys {
+		if k == key || (k.ID == key.ID && bytes.Equal(k.secret, key.secret)) {
+			return k
+		}
+	}
+
+	return nil
+}
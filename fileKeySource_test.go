@@ -0,0 +1,144 @@
+package fst
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyFile(t *testing.T, path string, secret string, modTime time.Time) {
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileKeySource_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	now := time.Now()
+	writeKeyFile(t, path, "secret1", now.Add(-time.Hour))
+
+	keySource, err := NewFileKeySource(&FileKeySourceConfig{
+		Path:         path,
+		PollInterval: time.Hour,
+		GracePeriod:  time.Millisecond * 200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keySource.Close()
+
+	converter := NewConverter(&ConverterConfig{
+		KeySource: keySource,
+	})
+
+	if string(converter.SecretKey()) != "secret1" {
+		t.Fatal("unexpected secret key: ", string(converter.SecretKey()))
+	}
+
+	oldToken := converter.NewToken([]byte(`token`))
+	oldKey := keySource.SigningKey()
+
+	writeKeyFile(t, path, "secret2", now)
+	if err = keySource.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(converter.SecretKey()) != "secret2" {
+		t.Fatal("key is not reloaded")
+	}
+
+	newToken := converter.NewToken([]byte(`token`))
+	if _, err = converter.ParseToken(newToken); err != nil {
+		t.Fatal("new token parse err: ", err)
+	}
+
+	if _, err = converter.ParseToken(oldToken); err != nil {
+		t.Fatal("old token is rejected during the grace period: ", err)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+	if err = keySource.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = converter.ParseToken(oldToken)
+	if !errors.Is(err, InvalidSignature) {
+		t.Fatal("old token is accepted after the grace period: ", err)
+	}
+
+	// The dropped key is closed by the next reload, the signing key is not.
+	if oldKey.isClosed() {
+		t.Fatal("dropped key is closed before the next reload")
+	}
+	if err = keySource.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !oldKey.isClosed() || keySource.SigningKey().isClosed() {
+		t.Fatal("dropped key is not closed after the next reload")
+	}
+}
+
+func TestFileKeySource_Directory(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeKeyFile(t, filepath.Join(dir, "v1"), "secret1", now)
+	writeKeyFile(t, filepath.Join(dir, ".hidden"), "hidden", now)
+
+	reloaded := ""
+	keySource, err := NewFileKeySource(&FileKeySourceConfig{
+		Path:         dir,
+		PollInterval: time.Millisecond * 10,
+		OnReload: func(signingKeyID string) {
+			reloaded = signingKeyID
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewConverter(&ConverterConfig{
+		KeySource: keySource,
+	})
+	oldToken := converter.NewToken([]byte(`token`))
+	v1 := keySource.SigningKey()
+
+	writeKeyFile(t, filepath.Join(dir, "v2"), "secret2", now)
+	time.Sleep(time.Millisecond * 100)
+	keySource.Close()
+
+	if reloaded != "v2" || keySource.SigningKey().ID != "v2" {
+		t.Fatal("the greatest key is not used for signing: ", reloaded)
+	}
+
+	if len(keySource.VerificationKeys()) != 2 {
+		t.Fatal("unexpected number of verification keys: ", len(keySource.VerificationKeys()))
+	}
+
+	// The unchanged key is not replaced by its copy, so it is never retired and closed.
+	if keySource.VerificationKeys()[1] != v1 || v1.isClosed() {
+		t.Fatal("unchanged key is replaced")
+	}
+
+	if _, err = converter.ParseToken(oldToken); err != nil {
+		t.Fatal("token signed by the previous version is rejected: ", err)
+	}
+}
+
+func TestFileKeySource_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+
+	if _, err := NewFileKeySource(&FileKeySourceConfig{Path: path}); err == nil {
+		t.Fatal("missing key file is accepted")
+	}
+
+	writeKeyFile(t, path, "", time.Now())
+	if _, err := NewFileKeySource(&FileKeySourceConfig{Path: path}); !errors.Is(err, EmptyKeyFile) {
+		t.Fatal("unexpected error: ", err)
+	}
+}
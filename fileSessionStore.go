@@ -0,0 +1,348 @@
+package fst
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Session log record layout:
+// [1 byte op] [uvarint idLen] [id] [8 bytes expiresAt Unix nanoseconds] [uvarint payloadLen] [payload] [4 bytes CRC-32]
+//
+// The delete records have only the op and the id before the CRC-32. The zero expiresAt means that the session never expires.
+// A torn record at the end of the log, left by a crash, is cut off when the log is opened. The record that runs past
+// the end of the log is torn only if no valid record follows its start, otherwise its length is damaged.
+// Any other damaged record makes the log unreadable, so the records after it are not lost.
+
+// CorruptedSessionLog means that a record of the FileSessionStore log is damaged, but it is not a torn record at its end.
+var CorruptedSessionLog = errors.New("fst: corrupted session log")
+
+const (
+	sessionRecordStore  = 1
+	sessionRecordDelete = 2
+)
+
+// FileSessionStore is a SessionStore that keeps the sessions in memory and appends all changes to a log file,
+// so the sessions survive restarts. The log only grows, use Compact to remove the deleted and expired sessions from it.
+//
+// Use NewFileSessionStore to create it.
+type FileSessionStore struct {
+	cfg    FileSessionStoreConfig
+	memory *MemorySessionStore
+
+	// mu serializes the changes, so the log and the memory have the same order of them.
+	mu   sync.Mutex
+	file *os.File
+}
+
+// FileSessionStoreConfig represents the configuration options for creating a new FileSessionStore.
+//
+// Path is the path to the log file. It is created if it does not exist.
+//
+// Sync makes every change to be flushed to the disk before it is applied.
+//
+// Now is the clock of the expiration of the sessions. It is time.Now by default.
+type FileSessionStoreConfig struct {
+	// Path is the path to the log file. It is created if it does not exist.
+	Path string
+	// Sync makes every change to be flushed to the disk with fsync before it is applied. It is slow,
+	// but no change is lost on a power failure. It is false by default and the changes are flushed by the OS.
+	Sync bool
+	// Now returns the current time used to check the expiration of the sessions, see MemorySessionStoreConfig.Now.
+	// It is time.Now by default.
+	Now func() time.Time
+}
+
+// NewFileSessionStore opens the log file and loads the sessions from it.
+//
+// It can return CorruptedSessionLog and the error of the file system.
+func NewFileSessionStore(cfg *FileSessionStoreConfig) (*FileSessionStore, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileSessionStore{
+		cfg:    *cfg,
+		memory: NewMemorySessionStore(&MemorySessionStoreConfig{Now: cfg.Now}),
+		file:   file,
+	}
+
+	if err = s.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// replay loads the sessions from the log and cuts off the torn record at its end.
+func (s *FileSessionStore) replay() error {
+	log, err := io.ReadAll(s.file)
+	if err != nil {
+		return err
+	}
+
+	now := s.memory.now()
+	offset := 0
+	for offset < len(log) {
+		op, id, payload, expiresAt, n := readSessionRecord(log[offset:])
+		if n < 0 {
+			return CorruptedSessionLog
+		}
+		if n == 0 {
+			if hasSessionRecord(log[offset+1:]) {
+				return CorruptedSessionLog
+			}
+
+			break
+		}
+		offset += n
+
+		if op == sessionRecordDelete {
+			delete(s.memory.sessions, string(id))
+			continue
+		}
+
+		// The payload is copied, so the log is not kept in memory.
+		session := storedSession{payload: append([]byte(nil), payload...), expiresAt: expiresAt}
+		if session.isExpired(now) {
+			delete(s.memory.sessions, string(id))
+		} else {
+			s.memory.sessions[string(id)] = session
+		}
+	}
+
+	if offset < len(log) {
+		return s.file.Truncate(int64(offset))
+	}
+
+	return nil
+}
+
+// Store appends the session to the log and saves it in memory.
+//
+// It can return the error of the file system.
+func (s *FileSessionStore) Store(id, payload []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(appendSessionRecord(nil, sessionRecordStore, id, payload, expiresAt)); err != nil {
+		return err
+	}
+
+	return s.memory.Store(id, payload, expiresAt)
+}
+
+// Load returns the payload of the session with the id or SessionNotFound. It does not read the file.
+func (s *FileSessionStore) Load(id []byte) ([]byte, error) {
+	return s.memory.Load(id)
+}
+
+// Delete appends the deletion of the session to the log and deletes it from memory.
+//
+// It can return the error of the file system.
+func (s *FileSessionStore) Delete(id []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(appendSessionRecord(nil, sessionRecordDelete, id, nil, time.Time{})); err != nil {
+		return err
+	}
+
+	return s.memory.Delete(id)
+}
+
+// write appends the record to the log. It must be called with the locked mu,
+// so the changes are applied to the memory in the same order as they are written.
+func (s *FileSessionStore) write(record []byte) error {
+	if _, err := s.file.Write(record); err != nil {
+		return err
+	}
+
+	if s.cfg.Sync {
+		return s.file.Sync()
+	}
+
+	return nil
+}
+
+// Compact rewrites the log with only the live sessions. The new log is written to a temporary file
+// and replaces the old one atomically, so the log is never lost.
+//
+// It can return the error of the file system.
+func (s *FileSessionStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.memory.now()
+	var log []byte
+
+	s.memory.mu.RLock()
+	for id, session := range s.memory.sessions {
+		if !session.isExpired(now) {
+			log = appendSessionRecord(log, sessionRecordStore, []byte(id), session.payload, session.expiresAt)
+		}
+	}
+	s.memory.mu.RUnlock()
+
+	tmpPath := s.cfg.Path + ".tmp"
+	if err := writeFileSync(tmpPath, log); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// The new log is opened before the rename, so the store keeps writing to the old one if it fails.
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, s.cfg.Path); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	s.file.Close()
+	s.file = file
+
+	return nil
+}
+
+// Close closes the log file. The store must not be used after Close.
+func (s *FileSessionStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func appendSessionRecord(dst []byte, op byte, id, payload []byte, expiresAt time.Time) []byte {
+	start := len(dst)
+
+	dst = append(dst, op)
+	dst = binary.AppendUvarint(dst, uint64(len(id)))
+	dst = append(dst, id...)
+
+	if op == sessionRecordStore {
+		var expiresAtNano int64
+		if !expiresAt.IsZero() {
+			expiresAtNano = expiresAt.UnixNano()
+		}
+
+		dst = binary.BigEndian.AppendUint64(dst, uint64(expiresAtNano))
+		dst = binary.AppendUvarint(dst, uint64(len(payload)))
+		dst = append(dst, payload...)
+	}
+
+	return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst[start:]))
+}
+
+// readSessionRecord reads the record from the buf and returns it with the number of read bytes.
+// n == 0 means that the record is torn: the buf ends before the record. n < 0 means that the record is corrupted.
+func readSessionRecord(buf []byte) (op byte, id, payload []byte, expiresAt time.Time, n int) {
+	if len(buf) == 0 {
+		return 0, nil, nil, time.Time{}, 0
+	}
+
+	op = buf[0]
+	if op != sessionRecordStore && op != sessionRecordDelete {
+		return 0, nil, nil, time.Time{}, -1
+	}
+	offset := 1
+
+	idLen, size := readRecordUvarint(buf[offset:])
+	if size <= 0 {
+		return 0, nil, nil, time.Time{}, size
+	}
+	if idLen > uint64(len(buf)-offset-size) {
+		return 0, nil, nil, time.Time{}, 0
+	}
+	offset += size
+	id = buf[offset : offset+int(idLen)]
+	offset += int(idLen)
+
+	if op == sessionRecordStore {
+		if len(buf) < offset+8 {
+			return 0, nil, nil, time.Time{}, 0
+		}
+
+		if expiresAtNano := int64(binary.BigEndian.Uint64(buf[offset:])); expiresAtNano != 0 {
+			expiresAt = time.Unix(0, expiresAtNano)
+		}
+		offset += 8
+
+		payloadLen, size := readRecordUvarint(buf[offset:])
+		if size <= 0 {
+			return 0, nil, nil, time.Time{}, size
+		}
+		if payloadLen > uint64(len(buf)-offset-size) {
+			return 0, nil, nil, time.Time{}, 0
+		}
+		offset += size
+		payload = buf[offset : offset+int(payloadLen)]
+		offset += int(payloadLen)
+	}
+
+	if len(buf) < offset+4 {
+		return 0, nil, nil, time.Time{}, 0
+	}
+
+	if binary.BigEndian.Uint32(buf[offset:]) != crc32.ChecksumIEEE(buf[:offset]) {
+		return 0, nil, nil, time.Time{}, -1
+	}
+
+	return op, id, payload, expiresAt, offset + 4
+}
+
+// hasSessionRecord reports whether the buf has a valid record at any offset. A damaged length makes the record
+// run past the end of the log like a torn one, but the records after it are still in the buf.
+func hasSessionRecord(buf []byte) bool {
+	for i := range buf {
+		if _, _, _, _, n := readSessionRecord(buf[i:]); n > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readRecordUvarint reads a uvarint of the record like getUvarint, but n == 0 means that the buf ends before the uvarint
+// and n < 0 means that the uvarint is malformed.
+func readRecordUvarint(buf []byte) (uint64, int) {
+	v, n := getUvarint(buf)
+	if n > 0 {
+		return v, n
+	}
+
+	if _, n = binary.Uvarint(buf); n == 0 {
+		return 0, 0
+	}
+
+	return 0, -1
+}
@@ -0,0 +1,390 @@
+// Package fsthttp provides the HTTP helpers of the proof-of-possession (PoP) tokens: the Fast Signed Tokens
+// that are bound to the Ed25519 key of the client, like DPoP (RFC 9449). A stolen PoP token is useless without
+// the private key of the client, because every request must be signed with it.
+//
+// The client sends the token in the "Authorization: PoP <token>" header and the proof of the possession of the key
+// in the "FST-Proof" header. The proof signs the method, the URL without the query, the time, a random nonce
+// and the hash of the token, so it can not be used for another request or replayed.
+package fsthttp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+var (
+	// NoProof means that the request has no PoP token or no proof.
+	NoProof = errors.New("fsthttp: no pop token or proof")
+	// InvalidProof means that the proof is malformed, is signed by another key than the key of the token
+	// or is made for another request or token.
+	InvalidProof = errors.New("fsthttp: invalid proof")
+	// ProofExpired means that the time of the proof differs from the server time by more than the ProofLifetime.
+	ProofExpired = errors.New("fsthttp: proof expired")
+	// ProofReplayed means that the proof has already been used.
+	ProofReplayed = errors.New("fsthttp: proof replayed")
+)
+
+const (
+	// AuthorizationScheme is the scheme of the Authorization header with the PoP token.
+	AuthorizationScheme = "PoP"
+	// ProofHeader is the name of the header with the proof.
+	ProofHeader = "FST-Proof"
+	// DefaultProofLifetime is the default time during which the proof is accepted.
+	DefaultProofLifetime = time.Minute
+
+	// ThumbprintSize is the size of the key thumbprint in the token.
+	ThumbprintSize = sha256.Size
+	nonceSize      = 16
+	proofSize      = ed25519.PublicKeySize + 8 + nonceSize + ed25519.SignatureSize
+
+	proofLabel = "fst pop proof"
+)
+
+// Proof layout (before base64):
+// [32 bytes public key] [8 bytes big-endian Unix milliseconds] [16 bytes nonce] [64 bytes signature]
+//
+// The signature is the Ed25519 signature of
+// label || uvarint len(method) || method || uvarint len(url) || url || time || nonce || SHA-256(token).
+//
+// The value of the PoP token is [32 bytes thumbprint of the public key] [value].
+
+// Thumbprint returns the JWK thumbprint (RFC 7638) of the Ed25519 public key, so the tokens are bound to the same
+// thumbprints as the DPoP access tokens.
+func Thumbprint(publicKey ed25519.PublicKey) []byte {
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + base64.RawURLEncoding.EncodeToString(publicKey) + `"}`))
+	return sum[:]
+}
+
+// requestURL returns the URL of the request without the query and the fragment.
+func requestURL(scheme, host, escapedPath string) string {
+	return scheme + "://" + host + escapedPath
+}
+
+func proofMessage(method, url string, proof []byte, token string) []byte {
+	tokenHash := sha256.Sum256([]byte(token))
+
+	message := make([]byte, 0, len(proofLabel)+len(method)+len(url)+8+8+nonceSize+len(tokenHash))
+	message = append(message, proofLabel...)
+	message = binary.AppendUvarint(message, uint64(len(method)))
+	message = append(message, method...)
+	message = binary.AppendUvarint(message, uint64(len(url)))
+	message = append(message, url...)
+	message = append(message, proof[ed25519.PublicKeySize:ed25519.PublicKeySize+8+nonceSize]...)
+
+	return append(message, tokenHash[:]...)
+}
+
+// Client signs the requests with the Ed25519 key of the client.
+//
+// # Example:
+//
+//	_, privateKey, _ := ed25519.GenerateKey(nil)
+//	client := fsthttp.NewClient(&fsthttp.ClientConfig{PrivateKey: privateKey})
+//
+//	// Send client.PublicKey() to the server to get the token.
+//
+//	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/files/1", nil)
+//	if err := client.Sign(req, token); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	resp, err := http.DefaultClient.Do(req)
+type Client struct {
+	privateKey ed25519.PrivateKey
+	now        func() time.Time
+}
+
+// ClientConfig represents the configuration options for creating a new Client.
+//
+// PrivateKey is the Ed25519 key of the client.
+//
+// Now is the clock of the proofs. It is time.Now by default.
+type ClientConfig struct {
+	// PrivateKey is the Ed25519 key of the client. The server binds the tokens to its public key.
+	PrivateKey ed25519.PrivateKey
+	// Now returns the current time put into the proofs. It is time.Now by default.
+	Now func() time.Time
+}
+
+// NewClient creates a new instance of the Client based on the provided fsthttp.ClientConfig.
+//
+// An example of usage can be found at Client.
+func NewClient(cfg *ClientConfig) *Client {
+	if len(cfg.PrivateKey) != ed25519.PrivateKeySize {
+		panic("fsthttp: ClientConfig.PrivateKey is not an Ed25519 private key")
+	}
+
+	c := &Client{
+		privateKey: cfg.PrivateKey,
+		now:        cfg.Now,
+	}
+
+	if c.now == nil {
+		c.now = time.Now
+	}
+
+	return c
+}
+
+// PublicKey returns the public key of the client that the server binds the tokens to.
+func (c *Client) PublicKey() ed25519.PublicKey {
+	return c.privateKey.Public().(ed25519.PublicKey)
+}
+
+// Sign sets the Authorization header with the token and the proof header with a new proof for the request.
+//
+// It can return the error of the random generator.
+func (c *Client) Sign(r *http.Request, token string) error {
+	proof := make([]byte, 0, proofSize)
+	proof = append(proof, c.PublicKey()...)
+	proof = binary.BigEndian.AppendUint64(proof, uint64(c.now().UnixMilli()))
+
+	proof = proof[:ed25519.PublicKeySize+8+nonceSize]
+	if _, err := rand.Read(proof[ed25519.PublicKeySize+8:]); err != nil {
+		return err
+	}
+
+	url := requestURL(r.URL.Scheme, r.URL.Host, r.URL.EscapedPath())
+	proof = append(proof, ed25519.Sign(c.privateKey, proofMessage(r.Method, url, proof, token))...)
+
+	r.Header.Set("Authorization", AuthorizationScheme+" "+token)
+	r.Header.Set(ProofHeader, base64.RawURLEncoding.EncodeToString(proof))
+
+	return nil
+}
+
+// Transport is an http.RoundTripper that signs every request with the Client and the Token.
+type Transport struct {
+	// Client signs the requests.
+	Client *Client
+	// Token is the PoP token of the Client.
+	Token string
+	// Base is the http.RoundTripper that sends the signed requests. It is http.DefaultTransport by default.
+	Base http.RoundTripper
+}
+
+// RoundTrip signs the copy of the request and sends it with the Base.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	signed := r.Clone(r.Context())
+	if err := t.Client.Sign(signed, t.Token); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signed)
+}
+
+// Server issues the PoP tokens and verifies the requests signed by the Client.
+//
+// # Example:
+//
+//	server := fsthttp.NewServer(&fsthttp.ServerConfig{
+//		Converter: fst.NewEncodedConverter(&fst.ConverterConfig{
+//			SecretKey:      []byte(`secret`),
+//			ExpirationTime: time.Hour,
+//		}),
+//	})
+//
+//	// On login, with the public key of the client:
+//	token, err := server.NewToken(publicKey, []byte(`user 1`))
+//
+//	http.Handle("/files/", server.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//		value, _ := fsthttp.ValueFromContext(r.Context())
+//		fmt.Fprintln(w, string(value)) // user 1
+//	})))
+type Server struct {
+	converter     *fst.EncodedConverter
+	proofLifetime time.Duration
+	replayCache   ReplayCache
+	requestURL    func(r *http.Request) string
+	onError       func(w http.ResponseWriter, r *http.Request, err error)
+	now           func() time.Time
+}
+
+// ServerConfig represents the configuration options for creating a new Server.
+//
+// Converter is the EncodedConverter of the PoP tokens.
+//
+// ProofLifetime is the time during which the proof is accepted. It is DefaultProofLifetime by default.
+//
+// ReplayCache remembers the nonces of the accepted proofs. It is a new MemoryReplayCache by default.
+//
+// RequestURL returns the URL of the request as the client sees it. It is built from the request by default.
+//
+// OnError is called by the Handler when the request is rejected. It responds with 401 Unauthorized by default.
+//
+// Now is the clock of the proofs. It is time.Now by default.
+type ServerConfig struct {
+	// Converter is the EncodedConverter of the PoP tokens.
+	Converter *fst.EncodedConverter
+	// ProofLifetime is the time during which the proof is accepted, it also limits the allowed clock skew.
+	// The nonces are remembered for this time. It is DefaultProofLifetime by default.
+	ProofLifetime time.Duration
+	// ReplayCache remembers the nonces of the accepted proofs. It is a new MemoryReplayCache by default.
+	ReplayCache ReplayCache
+	// RequestURL returns the URL of the request without the query as the client sees it, like "https://api.example.com/files/1".
+	// It is built from the Host and the path of the request and the scheme is https if the request has TLS by default.
+	// Set it if the server is behind a proxy.
+	RequestURL func(r *http.Request) string
+	// OnError is called by the Handler when the request is rejected. It responds with 401 Unauthorized by default.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+	// Now returns the current time used to check the proofs. The default ReplayCache uses it too,
+	// a custom ReplayCache must use the same clock. It is time.Now by default.
+	Now func() time.Time
+}
+
+// NewServer creates a new instance of the Server based on the provided fsthttp.ServerConfig.
+//
+// An example of usage can be found at Server.
+func NewServer(cfg *ServerConfig) *Server {
+	if cfg.Converter == nil {
+		panic("fsthttp: ServerConfig.Converter is nil")
+	}
+
+	s := &Server{
+		converter:     cfg.Converter,
+		proofLifetime: cfg.ProofLifetime,
+		replayCache:   cfg.ReplayCache,
+		requestURL:    cfg.RequestURL,
+		onError:       cfg.OnError,
+		now:           cfg.Now,
+	}
+
+	if s.proofLifetime <= 0 {
+		s.proofLifetime = DefaultProofLifetime
+	}
+
+	if s.now == nil {
+		s.now = time.Now
+	}
+
+	if s.replayCache == nil {
+		s.replayCache = newMemoryReplayCache(s.now)
+	}
+
+	if s.requestURL == nil {
+		s.requestURL = func(r *http.Request) string {
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+
+			return requestURL(scheme, r.Host, r.URL.EscapedPath())
+		}
+	}
+
+	if s.onError == nil {
+		s.onError = func(w http.ResponseWriter, _ *http.Request, _ error) {
+			w.Header().Set("WWW-Authenticate", AuthorizationScheme)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		}
+	}
+
+	return s
+}
+
+// NewToken creates a new PoP token with the value that is bound to the public key of the client.
+//
+// It can return the error of the RemoteSigner of the Converter.
+func (s *Server) NewToken(publicKey ed25519.PublicKey, value []byte) (string, error) {
+	return s.NewTokenContext(context.Background(), publicKey, value)
+}
+
+// NewTokenContext creates a new PoP token like NewToken, but passes the ctx to the RemoteSigner.
+func (s *Server) NewTokenContext(ctx context.Context, publicKey ed25519.PublicKey, value []byte) (string, error) {
+	popValue := make([]byte, 0, ThumbprintSize+len(value))
+	popValue = append(popValue, Thumbprint(publicKey)...)
+	popValue = append(popValue, value...)
+
+	return s.converter.NewTokenContext(ctx, popValue)
+}
+
+// Verify verifies the PoP token and the proof of the request and returns the value of the token.
+//
+// It can return NoProof, InvalidProof, ProofExpired, ProofReplayed or the errors of the token parsing,
+// like fst.InvalidSignature or fst.TokenExpired.
+func (s *Server) Verify(r *http.Request) ([]byte, error) {
+	authorization := r.Header.Get("Authorization")
+	encodedProof := r.Header.Get(ProofHeader)
+
+	token, ok := strings.CutPrefix(authorization, AuthorizationScheme+" ")
+	if !ok || token == "" || encodedProof == "" {
+		return nil, NoProof
+	}
+
+	popValue, err := s.converter.ParseTokenContext(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(popValue) < ThumbprintSize {
+		return nil, fst.InvalidTokenFormat
+	}
+
+	proof, err := base64.RawURLEncoding.DecodeString(encodedProof)
+	if err != nil || len(proof) != proofSize {
+		return nil, InvalidProof
+	}
+
+	publicKey := ed25519.PublicKey(proof[:ed25519.PublicKeySize])
+	if subtle.ConstantTimeCompare(Thumbprint(publicKey), popValue[:ThumbprintSize]) != 1 {
+		return nil, InvalidProof
+	}
+
+	signature := proof[proofSize-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, proofMessage(r.Method, s.requestURL(r), proof, token), signature) {
+		return nil, InvalidProof
+	}
+
+	now := s.now()
+	signedAt := time.UnixMilli(int64(binary.BigEndian.Uint64(proof[ed25519.PublicKeySize:])))
+	if signedAt.Before(now.Add(-s.proofLifetime)) || signedAt.After(now.Add(s.proofLifetime)) {
+		return nil, ProofExpired
+	}
+
+	// The proof is valid until signedAt + proofLifetime, so its nonce must be remembered until then.
+	nonce := proof[ed25519.PublicKeySize+8 : ed25519.PublicKeySize+8+nonceSize]
+	if !s.replayCache.Add(nonce, signedAt.Add(s.proofLifetime)) {
+		return nil, ProofReplayed
+	}
+
+	return popValue[ThumbprintSize:], nil
+}
+
+type valueContextKey struct{}
+
+// ValueFromContext returns the value of the PoP token verified by the Handler.
+// ok is false if the request has not passed through it.
+func ValueFromContext(ctx context.Context) (value []byte, ok bool) {
+	value, ok = ctx.Value(valueContextKey{}).([]byte)
+	return value, ok
+}
+
+// Handler returns an http.Handler that passes only the requests with the valid PoP token and proof to the next handler.
+// The value of the token can be read with ValueFromContext.
+func (s *Server) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, err := s.Verify(r)
+		if err != nil {
+			s.onError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), valueContextKey{}, value)))
+	})
+}
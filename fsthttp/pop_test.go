@@ -0,0 +1,160 @@
+package fsthttp
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+func newTestServer(t *testing.T) (*Server, *Client, string) {
+	server := NewServer(&ServerConfig{
+		Converter: fst.NewEncodedConverter(&fst.ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Hour,
+		}),
+	})
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(&ClientConfig{PrivateKey: privateKey})
+
+	token, err := server.NewToken(client.PublicKey(), []byte(`user 1`))
+	if err != nil {
+		t.Fatal("Token create err: ", err)
+	}
+
+	return server, client, token
+}
+
+func TestServer_Verify(t *testing.T) {
+	server, client, token := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/files/1?download=1", nil)
+	if err := client.Sign(r, token); err != nil {
+		t.Fatal("Sign err: ", err)
+	}
+
+	value, err := server.Verify(r)
+	if err != nil || string(value) != "user 1" {
+		t.Fatal("Verify err: ", err)
+	}
+
+	if _, err = server.Verify(r); !errors.Is(err, ProofReplayed) {
+		t.Fatal("Replayed proof err: ", err)
+	}
+
+	// The proof is made for another request.
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+	client.Sign(r, token)
+	r.Method = http.MethodDelete
+	if _, err = server.Verify(r); !errors.Is(err, InvalidProof) {
+		t.Fatal("Proof of another method err: ", err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+	client.Sign(r, token)
+	r.URL.Path = "/files/2"
+	if _, err = server.Verify(r); !errors.Is(err, InvalidProof) {
+		t.Fatal("Proof of another URL err: ", err)
+	}
+
+	// The token is stolen, but the thief has another key.
+	_, thiefKey, _ := ed25519.GenerateKey(nil)
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+	NewClient(&ClientConfig{PrivateKey: thiefKey}).Sign(r, token)
+	if _, err = server.Verify(r); !errors.Is(err, InvalidProof) {
+		t.Fatal("Proof of another key err: ", err)
+	}
+
+	// The token has no proof.
+	r = httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+	r.Header.Set("Authorization", AuthorizationScheme+" "+token)
+	if _, err = server.Verify(r); !errors.Is(err, NoProof) {
+		t.Fatal("Request without proof err: ", err)
+	}
+}
+
+func TestServer_ProofExpired(t *testing.T) {
+	server, client, token := newTestServer(t)
+	signedAt := time.Now()
+	client.now = func() time.Time { return signedAt }
+
+	for _, skew := range []time.Duration{DefaultProofLifetime + time.Second, -DefaultProofLifetime - time.Second} {
+		server.now = func() time.Time { return signedAt.Add(skew) }
+
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+		client.Sign(r, token)
+
+		if _, err := server.Verify(r); !errors.Is(err, ProofExpired) {
+			t.Fatal("Proof with skew ", skew, " err: ", err)
+		}
+	}
+
+	// The Server with the clock in the past accepts the proofs of the Client with the same clock once.
+	past := func() time.Time { return signedAt.Add(-time.Hour * 24) }
+	server = NewServer(&ServerConfig{Converter: server.converter, Now: past})
+	client.now = past
+
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/files/1", nil)
+	client.Sign(r, token)
+	if _, err := server.Verify(r); err != nil {
+		t.Fatal("Proof of the past clock err: ", err)
+	}
+	if _, err := server.Verify(r); !errors.Is(err, ProofReplayed) {
+		t.Fatal("Replayed proof of the past clock err: ", err)
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	server, client, token := newTestServer(t)
+
+	httpServer := httptest.NewServer(server.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, _ := ValueFromContext(r.Context())
+		w.Write(value)
+	})))
+	defer httpServer.Close()
+
+	httpClient := &http.Client{Transport: &Transport{Client: client, Token: token}}
+	for range 2 {
+		resp, err := httpClient.Get(httpServer.URL + "/files/1?download=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "user 1" {
+			t.Fatal("Response is ", resp.Status, string(body))
+		}
+	}
+
+	resp, err := http.Get(httpServer.URL + "/files/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal("Response without proof is ", resp.Status)
+	}
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	cache := NewMemoryReplayCache()
+	expiresAt := time.Now().Add(time.Minute)
+
+	if !cache.Add([]byte(`nonce`), expiresAt) || cache.Add([]byte(`nonce`), expiresAt) {
+		t.Fatal("Nonce is not remembered")
+	}
+
+	if !cache.Add([]byte(`expired`), time.Now().Add(-time.Second)) || !cache.Add([]byte(`expired`), expiresAt) {
+		t.Fatal("Expired nonce is remembered")
+	}
+}
@@ -0,0 +1,77 @@
+package fsthttp
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers the nonces of the accepted proofs, so a proof can not be used twice.
+// Use a shared ReplayCache, like one in Redis, if the tokens are verified by many instances.
+//
+// All methods must be safe for concurrent use.
+type ReplayCache interface {
+	// Add remembers the nonce until the expiresAt and reports whether it was added.
+	// It returns false if the nonce is already remembered.
+	Add(nonce []byte, expiresAt time.Time) bool
+}
+
+// sweepInterval is the minimum interval between the removals of the expired nonces from the MemoryReplayCache.
+const sweepInterval = time.Minute
+
+// MemoryReplayCache is a ReplayCache that keeps the nonces in memory.
+// The expired nonces are removed lazily, when the cache is used.
+//
+// Use NewMemoryReplayCache to create it.
+type MemoryReplayCache struct {
+	now       func() time.Time
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryReplayCache creates a new empty MemoryReplayCache.
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return newMemoryReplayCache(time.Now)
+}
+
+// newMemoryReplayCache creates a new empty MemoryReplayCache with the clock of the Server.
+func newMemoryReplayCache(now func() time.Time) *MemoryReplayCache {
+	return &MemoryReplayCache{
+		now:       now,
+		nonces:    make(map[string]time.Time),
+		lastSweep: now(),
+	}
+}
+
+// Add remembers the nonce until the expiresAt and reports whether it was added.
+func (c *MemoryReplayCache) Add(nonce []byte, expiresAt time.Time) bool {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > sweepInterval {
+		for n, e := range c.nonces {
+			if e.Before(now) {
+				delete(c.nonces, n)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if e, ok := c.nonces[string(nonce)]; ok && !e.Before(now) {
+		return false
+	}
+
+	c.nonces[string(nonce)] = expiresAt
+
+	return true
+}
+
+// Len returns the number of the remembered nonces, including the expired ones that are not removed yet.
+func (c *MemoryReplayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.nonces)
+}
@@ -0,0 +1,59 @@
+// Package fstmetrics provides the fst.Observer adapters that export the metrics of the Converters
+// with expvar and in the Prometheus text format.
+//
+// It is a separate package, because importing expvar registers the /debug/vars handler in http.DefaultServeMux.
+package fstmetrics
+
+import (
+	"context"
+	"expvar"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+// Expvar is an fst.Observer that publishes the number of the operations by their outcomes and their total duration
+// as an expvar.Map, like {"parse_token.ok": 10, "parse_token.expired": 1, "parse_token.duration_ns": 12345}.
+//
+// # Example:
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		SecretKey: []byte(`secret`),
+//		Observer:  fstmetrics.NewExpvar("fst"),
+//	})
+type Expvar struct {
+	vars *expvar.Map
+}
+
+const (
+	operationCount = int(fst.OperationParseToken) + 1
+	reasonCount    = int(fst.ReasonOther) + 1
+)
+
+// counterNames are the keys of the counters of the operations and the reasons, so Observe does not allocate.
+var counterNames, durationNames = func() (counters [operationCount][reasonCount]string, durations [operationCount]string) {
+	for operation := range counters {
+		name := fst.Operation(operation).String()
+		for reason := range counters[operation] {
+			counters[operation][reason] = name + "." + fst.Reason(reason).String()
+		}
+		durations[operation] = name + ".duration_ns"
+	}
+
+	return counters, durations
+}()
+
+// NewExpvar creates a new Expvar and publishes its map with the name. It panics if the name is already published,
+// like expvar.NewMap.
+func NewExpvar(name string) *Expvar {
+	return &Expvar{vars: expvar.NewMap(name)}
+}
+
+// Observe counts the operation.
+func (e *Expvar) Observe(_ context.Context, event fst.Event) {
+	if int(event.Operation) >= operationCount || int(event.Reason) >= reasonCount {
+		return
+	}
+
+	e.vars.Add(counterNames[event.Operation][event.Reason], 1)
+	e.vars.Add(durationNames[event.Operation], int64(event.Duration))
+}
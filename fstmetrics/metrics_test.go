@@ -0,0 +1,55 @@
+package fstmetrics
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+func TestObservers(t *testing.T) {
+	prometheus := NewPrometheus()
+	vars := NewExpvar("fst_test")
+
+	for _, observer := range []fst.Observer{prometheus, vars} {
+		converter := fst.NewConverter(&fst.ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Hour,
+			Observer:       observer,
+		})
+
+		token := converter.NewToken([]byte(`token`))
+		converter.ParseToken(token)
+		converter.ParseToken(token[:len(token)-1])
+	}
+
+	recorder := httptest.NewRecorder()
+	prometheus.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, line := range []string{
+		`fst_tokens_total{operation="new_token",reason="ok"} 1`,
+		`fst_tokens_total{operation="parse_token",reason="ok"} 1`,
+		`fst_tokens_total{operation="parse_token",reason="invalid_signature"} 1`,
+		`fst_token_duration_seconds_bucket{operation="parse_token",le="+Inf"} 2`,
+		`fst_token_duration_seconds_count{operation="parse_token"} 2`,
+	} {
+		if !strings.Contains(recorder.Body.String(), line+"\n") {
+			t.Error("Metrics have no ", line, ":\n", recorder.Body.String())
+		}
+	}
+
+	published := expvar.Get("fst_test").(*expvar.Map)
+	for name, expected := range map[string]string{
+		"new_token.ok":                  "1",
+		"parse_token.ok":                "1",
+		"parse_token.invalid_signature": "1",
+	} {
+		if v := published.Get(name); v == nil || v.String() != expected {
+			t.Error("expvar ", name, " is ", v)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package fstmetrics
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+// durationBuckets are the upper bounds of the latency histogram. Most tokens are signed and parsed in microseconds,
+// the long tail is the RemoteSigner.
+var durationBuckets = [...]time.Duration{
+	time.Microsecond,
+	time.Microsecond * 5,
+	time.Microsecond * 10,
+	time.Microsecond * 50,
+	time.Microsecond * 100,
+	time.Microsecond * 500,
+	time.Millisecond,
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+}
+
+// Prometheus is an fst.Observer that is also an http.Handler that exposes the metrics in the Prometheus text format:
+//
+//	fst_tokens_total{operation="parse_token",reason="invalid_signature"} 3
+//	fst_token_duration_seconds_bucket{operation="parse_token",le="1e-06"} 120
+//
+// Observe does not allocate and does not lock, so it does not slow the Converter down.
+// It has no dependency on the Prometheus client, so it can be scraped by any compatible collector.
+//
+// # Example:
+//
+//	metrics := fstmetrics.NewPrometheus()
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		SecretKey: []byte(`secret`),
+//		Observer:  metrics,
+//	})
+//
+//	http.Handle("/metrics", metrics)
+type Prometheus struct {
+	operations [operationCount]operationMetrics
+}
+
+type operationMetrics struct {
+	reasons [reasonCount]atomic.Uint64
+	// buckets are not cumulative, they are summed when the metrics are written.
+	buckets       [len(durationBuckets) + 1]atomic.Uint64
+	durationNanos atomic.Uint64
+}
+
+// NewPrometheus creates a new Prometheus.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{}
+}
+
+// Observe counts the operation.
+func (p *Prometheus) Observe(_ context.Context, event fst.Event) {
+	if int(event.Operation) >= operationCount || int(event.Reason) >= reasonCount {
+		return
+	}
+
+	metrics := &p.operations[event.Operation]
+	metrics.reasons[event.Reason].Add(1)
+	metrics.durationNanos.Add(uint64(event.Duration))
+
+	bucket := len(durationBuckets)
+	for i, bound := range durationBuckets {
+		if event.Duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	metrics.buckets[bucket].Add(1)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	b.WriteString("# HELP fst_tokens_total Number of the created and parsed tokens by their outcome.\n")
+	b.WriteString("# TYPE fst_tokens_total counter\n")
+	for operation := range p.operations {
+		for reason := range p.operations[operation].reasons {
+			b.WriteString(`fst_tokens_total{operation="` + fst.Operation(operation).String() + `",reason="` + fst.Reason(reason).String() + `"} `)
+			b.WriteString(strconv.FormatUint(p.operations[operation].reasons[reason].Load(), 10))
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString("# HELP fst_token_duration_seconds Latency of the token operations.\n")
+	b.WriteString("# TYPE fst_token_duration_seconds histogram\n")
+	for operation := range p.operations {
+		metrics := &p.operations[operation]
+		labels := `{operation="` + fst.Operation(operation).String() + `"`
+
+		var count uint64
+		for i := range metrics.buckets {
+			count += metrics.buckets[i].Load()
+
+			le := "+Inf"
+			if i < len(durationBuckets) {
+				le = strconv.FormatFloat(durationBuckets[i].Seconds(), 'g', -1, 64)
+			}
+
+			b.WriteString("fst_token_duration_seconds_bucket" + labels + `,le="` + le + `"} `)
+			b.WriteString(strconv.FormatUint(count, 10))
+			b.WriteByte('\n')
+		}
+
+		b.WriteString("fst_token_duration_seconds_sum" + labels + "} ")
+		b.WriteString(strconv.FormatFloat(time.Duration(metrics.durationNanos.Load()).Seconds(), 'g', -1, 64))
+		b.WriteByte('\n')
+
+		b.WriteString("fst_token_duration_seconds_count" + labels + "} ")
+		b.WriteString(strconv.FormatUint(count, 10))
+		b.WriteByte('\n')
+	}
+}
@@ -0,0 +1,99 @@
+package fst
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyClosed means that the key of the Converter has been closed, so it can not sign and verify tokens anymore.
+var KeyClosed = errors.New("fst: key is closed")
+
+// The secrets of the keys are copied into the guarded memory, that is locked in RAM and excluded from the core dumps
+// on Linux, so they are not written to the swap or to the crash dumps. Close zeroes them.
+//
+// Zeroization is a best effort: the states derived from the secret, like the HMAC pads and the AES key schedule,
+// live in the Go heap. Close zeroes the ones it can reach and drops the pooled ones, but the runtime may keep their copies.
+
+// zeroer is implemented by the macs and the hashes that can zero their key material.
+type zeroer interface {
+	zero()
+}
+
+// statePool is a sync.Pool of the states derived from a secret, that can be closed. get never returns nil,
+// even after close, so the running operations can finish, but the results of the closed states must be rejected.
+type statePool struct {
+	pool   sync.Pool
+	new    func() interface{}
+	zero   func(x interface{})
+	closed atomic.Bool
+}
+
+func (p *statePool) get() interface{} {
+	if x := p.pool.Get(); x != nil {
+		return x
+	}
+
+	return p.new()
+}
+
+// put returns the state to the pool or zeroes it if the pool is closed.
+func (p *statePool) put(x interface{}) {
+	if p.closed.Load() {
+		p.zero(x)
+		return
+	}
+
+	p.pool.Put(x)
+}
+
+// close zeroes the pooled states and makes put zero the states that are in use now.
+// The states cached by other Ps can not be taken and are released by the next garbage collections.
+func (p *statePool) close() {
+	p.closed.Store(true)
+	for x := p.pool.Get(); x != nil; x = p.pool.Get() {
+		p.zero(x)
+	}
+}
+
+func (p *statePool) isClosed() bool {
+	return p.closed.Load()
+}
+
+// guardedMemory owns the guarded copy of a secret. It is freed by free or by its finalizer, when it is collected,
+// so everything that reads the secret must hold the guardedMemory, not only the slice.
+type guardedMemory struct {
+	mem  []byte
+	once sync.Once
+}
+
+// newGuardedMemory copies the secret into the guarded memory.
+func newGuardedMemory(secret []byte) *guardedMemory {
+	g := &guardedMemory{}
+	if len(secret) == 0 {
+		return g
+	}
+
+	g.mem = allocGuarded(len(secret))
+	copy(g.mem, secret)
+	runtime.SetFinalizer(g, (*guardedMemory).free)
+
+	return g
+}
+
+// zero zeroes the memory, but does not free it, so it can be still read.
+func (g *guardedMemory) zero() {
+	clear(g.mem)
+}
+
+// free zeroes and frees the memory. The memory must not be used after it.
+func (g *guardedMemory) free() {
+	g.once.Do(func() {
+		if g.mem != nil {
+			freeGuarded(g.mem)
+			g.mem = nil
+			runtime.SetFinalizer(g, nil)
+		}
+	})
+}
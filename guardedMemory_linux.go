@@ -0,0 +1,31 @@
+//go:build linux
+
+package fst
+
+import "syscall"
+
+// madvDontDump is MADV_DONTDUMP, that is not defined by the syscall package.
+const madvDontDump = 0x10
+
+// allocGuarded returns the memory that is mapped outside the Go heap, locked in RAM and excluded from the core dumps.
+// The mlock fails if RLIMIT_MEMLOCK is too low, then the memory is only excluded from the core dumps.
+// If the memory can not be mapped, it falls back to the Go heap.
+func allocGuarded(size int) []byte {
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return make([]byte, size)
+	}
+
+	_ = syscall.Mlock(mem)
+	_ = syscall.Madvise(mem, madvDontDump)
+
+	return mem
+}
+
+// freeGuarded zeroes and unmaps the memory returned by allocGuarded. The memory must not be used after it.
+func freeGuarded(mem []byte) {
+	clear(mem)
+
+	// Munmap also unlocks the memory. It fails for the memory from the Go heap fallback, that is collected by the GC.
+	_ = syscall.Munmap(mem)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fst
+
+// allocGuarded returns the memory from the Go heap, because the memory locking is supported only on Linux.
+func allocGuarded(size int) []byte {
+	return make([]byte, size)
+}
+
+// freeGuarded zeroes the memory returned by allocGuarded.
+func freeGuarded(mem []byte) {
+	clear(mem)
+}
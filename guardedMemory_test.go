@@ -0,0 +1,158 @@
+package fst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKey_SecretIsCopied(t *testing.T) {
+	secret := []byte(`secret`)
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      secret,
+		ExpirationTime: time.Hour,
+	})
+	defer converter.Close()
+
+	token := converter.NewToken([]byte(`value`))
+	clear(secret)
+
+	value, err := converter.ParseToken(token)
+	if err != nil || string(value) != "value" {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	secretKey := converter.SecretKey()
+	if string(secretKey) != "secret" {
+		t.Fatal("SecretKey is ", string(secretKey))
+	}
+	clear(secretKey)
+
+	if _, err = converter.ParseToken(token); err != nil {
+		t.Fatal("SecretKey does not return the copy: ", err)
+	}
+}
+
+func TestConverter_Close(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+
+	token := converter.NewToken([]byte(`value`))
+	segmentedToken, err := converter.NewSegmentedToken([]byte(`public`), []byte(`private`))
+	if err != nil {
+		t.Fatal("Segmented token create err: ", err)
+	}
+	converter.Close()
+	converter.Close()
+
+	if _, err := converter.NewTokenContext(context.Background(), []byte(`value`)); !errors.Is(err, KeyClosed) {
+		t.Fatal("New token after Close err: ", err)
+	}
+
+	if _, err := converter.ParseToken(token); !errors.Is(err, KeyClosed) {
+		t.Fatal("Token parse after Close err: ", err)
+	}
+
+	if results := converter.ParseTokens([][]byte{token}); !errors.Is(results[0].Err, KeyClosed) {
+		t.Fatal("Tokens parse after Close err: ", results[0].Err)
+	}
+
+	if _, err := converter.NewSegmentedToken([]byte(`public`), []byte(`private`)); !errors.Is(err, KeyClosed) {
+		t.Fatal("Segmented token after Close err: ", err)
+	}
+
+	if _, _, err = converter.ParseSegmentedToken(segmentedToken); !errors.Is(err, KeyClosed) {
+		t.Fatal("Segmented token parse after Close err: ", err)
+	}
+
+	if !bytes.Equal(converter.key.secret, make([]byte, len("secret"))) {
+		t.Fatal("Secret is not zeroed")
+	}
+
+	if converter.SecretKey() != nil {
+		t.Fatal("SecretKey is returned after Close")
+	}
+}
+
+func TestKey_KeyedMacsOnFirstUse(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+	defer converter.Close()
+
+	if _, err := converter.ParseToken(converter.NewToken([]byte(`value`))); err != nil {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	// The HMAC key has no copies of the secret for BLAKE2b and SipHash.
+	if converter.key.symmetric.keyed != [algorithmCount]mac{} {
+		t.Fatal("keyed macs are created for the HMAC key")
+	}
+}
+
+func TestKey_CloseZeroesMacs(t *testing.T) {
+	key := NewKey("key 1", []byte(`secret`), nil)
+	engine := key.algorithmMac(AlgorithmHMAC).(*macEngine)
+	blake2b := key.algorithmMac(AlgorithmBLAKE2b256).(*keyedMac)
+	key.Close()
+
+	if !bytes.Equal(engine.innerState, make([]byte, len(engine.innerState))) ||
+		!bytes.Equal(engine.outerState, make([]byte, len(engine.outerState))) {
+		t.Fatal("HMAC states are not zeroed")
+	}
+
+	if !bytes.Equal(blake2b.key, make([]byte, len(blake2b.key))) {
+		t.Fatal("BLAKE2b key is not zeroed")
+	}
+}
+
+func TestKey_CloseWhileRunning(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmHMAC, AlgorithmBLAKE2b256, AlgorithmSipHash128} {
+		key := NewKey("key 1", []byte(`secret`), nil)
+		converter := NewConverter(&ConverterConfig{
+			Algorithm: algorithm,
+			KeySource: NewStaticKeySource(key),
+		})
+		token := converter.NewToken([]byte(`value`))
+
+		var stream bytes.Buffer
+		if err := converter.SignStream(&stream, bytes.NewReader(make([]byte, StreamChunkSize*3))); err != nil {
+			t.Fatal("SignStream err: ", err)
+		}
+		// The reader verifies the first chunk before Close and keeps the mac of the key.
+		reader := converter.NewStreamReader(&stream)
+		if _, err := reader.Read(make([]byte, 1)); err != nil {
+			t.Fatal("Stream read err: ", err)
+		}
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for range 100 {
+					if _, err := converter.NewTokenContext(context.Background(), []byte(`value`)); err != nil && !errors.Is(err, KeyClosed) {
+						t.Error("NewTokenContext err: ", err)
+					}
+					if _, err := converter.ParseToken(token); err != nil && !errors.Is(err, KeyClosed) {
+						t.Error("ParseToken err: ", err)
+					}
+				}
+			}()
+		}
+
+		key.Close()
+		wg.Wait()
+
+		// The rest of the stream is not verified by the zeroed mac, but the reader does not panic.
+		if _, err := io.ReadAll(reader); !errors.Is(err, KeyClosed) {
+			t.Fatal("Stream read after Close err: ", err)
+		}
+	}
+}
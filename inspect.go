@@ -0,0 +1,135 @@
+package fst
+
+import (
+	"encoding/base64"
+	"time"
+)
+
+// TokenInfo is the information about the token decoded by Inspect.
+//
+// # Be careful!
+//
+// TokenInfo is NOT verified, anyone can forge it. Use it only for debugging, logging and routing,
+// and never to make decisions about access.
+type TokenInfo struct {
+	// HasHeader reports whether the token has the header.
+	HasHeader bool
+	// Algorithm is the algorithm ID from the header. It is AlgorithmHMAC for the tokens without the header.
+	Algorithm Algorithm
+	// Segmented reports whether the token is a segmented token. For the segmented tokens,
+	// SignatureLen is the size of the AEAD tag and PayloadLen is the size of the encrypted private segment.
+	Segmented bool
+	// VarintFraming reports whether the lengths are encoded as uvarints.
+	VarintFraming bool
+	// TimeResolution is the resolution of the timestamp. It is zero if the timestamp is 8 bytes of Unix seconds.
+	TimeResolution time.Duration
+
+	// HasTimestamp reports whether the token has the timestamp.
+	HasTimestamp bool
+	// IssuedAt is the time of issue. It is zero if the token has no timestamp.
+	IssuedAt time.Time
+	// ExpiresAt is the expiration time according to the ExpirationTime of the Converter.
+	// It is zero if the token has no timestamp.
+	ExpiresAt time.Time
+	// Expired reports whether the token is expired according to the ExpirationTime of the Converter.
+	Expired bool
+
+	// SignatureLen is the length of the signature.
+	SignatureLen int
+	// PublicLen is the length of the public segment of the segmented token.
+	PublicLen int
+	// PayloadLen is the length of the payload.
+	PayloadLen int
+}
+
+var timeEncodingResolutions = [timeEncodingCount]time.Duration{
+	timeEncodingSeconds:      time.Second,
+	timeEncodingMilliseconds: time.Millisecond,
+	timeEncodingMicroseconds: time.Microsecond,
+	timeEncodingNanoseconds:  time.Nanosecond,
+}
+
+// Inspect decodes the framing of the token without verifying it. The token is decoded as the Converter
+// would parse it, because the tokens without the header can not be decoded without the configuration.
+//
+// # Be careful!
+//
+// The returned TokenInfo is NOT verified and must not be trusted, see TokenInfo.
+//
+// It can return InvalidTokenFormat.
+func (c *Converter) Inspect(token []byte) (TokenInfo, error) {
+	if len(token) > 0 && isSegmentedHeader(token[0]) {
+		if info, err := c.inspectSegmented(token); err == nil || c.withHeader {
+			return info, err
+		}
+	}
+
+	f, err := c.decodeFrame(token)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	info := TokenInfo{
+		HasHeader:      c.withHeader,
+		Algorithm:      f.algorithm,
+		VarintFraming:  f.varint,
+		TimeResolution: timeEncodingResolutions[f.timeEncoding],
+		SignatureLen:   len(f.signature),
+		PayloadLen:     len(f.payload),
+	}
+	c.inspectTimestamp(&info, f.issuedAt, f.timeEncoding, c.expires)
+
+	return info, nil
+}
+
+func (c *Converter) inspectSegmented(token []byte) (TokenInfo, error) {
+	f, err := parseSegmentedFrame(token)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	info := TokenInfo{
+		HasHeader:      true,
+		Algorithm:      segmentsAlgorithmID,
+		Segmented:      true,
+		VarintFraming:  true,
+		TimeResolution: timeEncodingResolutions[f.timeEncoding],
+		SignatureLen:   segmentsTagSize,
+		PublicLen:      len(f.public),
+		PayloadLen:     len(f.sealed) - segmentsTagSize,
+	}
+	c.inspectTimestamp(&info, f.issuedAt, f.timeEncoding, f.timeEncoding != timeEncodingUnixSeconds)
+
+	return info, nil
+}
+
+func (c *Converter) inspectTimestamp(info *TokenInfo, issuedAt time.Time, timeEncoding byte, hasTimestamp bool) {
+	if !hasTimestamp {
+		return
+	}
+
+	info.HasTimestamp = true
+	info.IssuedAt = issuedAt
+	info.ExpiresAt = c.expiresAt(issuedAt, timeEncoding)
+	info.Expired = c.isExpired(issuedAt, timeEncoding, c.clock())
+}
+
+func isSegmentedHeader(header byte) bool {
+	return header&headerAlgorithmMask == segmentsAlgorithmID && header&headerVarintFlag != 0
+}
+
+// Inspect decodes the framing of the token without verifying it, see Converter.Inspect.
+//
+// # Be careful!
+//
+// The returned TokenInfo is NOT verified and must not be trusted, see TokenInfo.
+//
+// It can return InvalidTokenFormat or the error of base64 decoding.
+func (c *EncodedConverter) Inspect(token string) (TokenInfo, error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	return c.converter.Inspect(decodedToken)
+}
@@ -0,0 +1,81 @@
+package fst
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConverter_Inspect(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Millisecond * 100,
+		TimeResolution: time.Millisecond,
+		Algorithm:      AlgorithmBLAKE2b256,
+	})
+
+	before := time.Now().Truncate(time.Millisecond)
+	token := converter.NewToken([]byte(`token`))
+
+	info, err := converter.Inspect(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.HasHeader || info.Algorithm != AlgorithmBLAKE2b256 || !info.VarintFraming || info.Segmented {
+		t.Fatal("unexpected header fields: ", info)
+	}
+
+	if info.TimeResolution != time.Millisecond || !info.HasTimestamp || info.IssuedAt.Before(before) || info.Expired {
+		t.Fatal("unexpected timestamp: ", info)
+	}
+
+	if info.ExpiresAt.Sub(info.IssuedAt) != time.Millisecond*100 {
+		t.Fatal("unexpected expiration time: ", info.ExpiresAt)
+	}
+
+	if info.SignatureLen != 32 || info.PayloadLen != len(`token`) {
+		t.Fatal("unexpected lengths: ", info)
+	}
+
+	time.Sleep(time.Millisecond * 150)
+
+	// Inspect decodes the expired and forged tokens too.
+	token[len(token)-1] ^= 1
+	if info, err = converter.Inspect(token); err != nil || !info.Expired {
+		t.Fatal("expired token is not inspected: ", err)
+	}
+
+	if _, err = converter.Inspect(token[:3]); !errors.Is(err, InvalidTokenFormat) {
+		t.Fatal("malformed token inspect err: ", err)
+	}
+}
+
+func TestEncodedConverter_Inspect(t *testing.T) {
+	converter := NewEncodedConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute,
+	})
+
+	info, err := converter.Inspect(converter.NewToken([]byte(`token`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.HasHeader || info.TimeResolution != 0 || info.ExpiresAt.Sub(info.IssuedAt) != time.Minute {
+		t.Fatal("unexpected info of the token without the header: ", info)
+	}
+
+	token, err := converter.NewSegmentedToken([]byte(`public`), []byte(`private`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err = converter.Inspect(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Segmented || info.PublicLen != len(`public`) || info.PayloadLen != len(`private`) || !info.HasTimestamp {
+		t.Fatal("unexpected info of the segmented token: ", info)
+	}
+}
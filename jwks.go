@@ -0,0 +1,375 @@
+package fst
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InvalidJWKS means that the JWKS document is malformed or has no Ed25519 keys.
+var InvalidJWKS = errors.New("fst: invalid JWKS document")
+
+// maxJWKSSize limits the size of the fetched JWKS document, so a broken server can not exhaust the memory.
+const maxJWKSSize = 1 << 20
+
+// jwk is the JSON Web Key of the Ed25519 public key as defined in RFC 8037.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// MarshalJWKS returns the JWKS document with the public keys of the Ed25519 keys. The symmetric keys are skipped,
+// because their secrets must never be published.
+func MarshalJWKS(keys []*Key) ([]byte, error) {
+	document := jwks{Keys: make([]jwk, 0, len(keys))}
+	for _, key := range keys {
+		publicKey := key.PublicKey()
+		if publicKey == nil {
+			continue
+		}
+
+		document.Keys = append(document.Keys, jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			Kid: key.ID,
+			Alg: JWTAlgorithmEdDSA,
+			Use: "sig",
+		})
+	}
+
+	return json.Marshal(document)
+}
+
+// ParseJWKS returns the verification keys of the Ed25519 keys of the JWKS document. Other keys, like RSA keys, are skipped.
+//
+// It returns InvalidJWKS if the document is malformed or has no Ed25519 keys.
+func ParseJWKS(data []byte) ([]*Key, error) {
+	var document jwks
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, InvalidJWKS
+	}
+
+	keys := make([]*Key, 0, len(document.Keys))
+	for _, key := range document.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, InvalidJWKS
+		}
+
+		keys = append(keys, NewEd25519VerificationKey(key.Kid, publicKey))
+	}
+
+	if len(keys) == 0 {
+		return nil, InvalidJWKS
+	}
+
+	return keys, nil
+}
+
+// JWKSHandler returns an http.Handler that serves the JWKS document with the public keys of the verification keys
+// of the source. The document is built on every request, so the rotated keys are published at once.
+//
+// # Example:
+//
+//	http.Handle("/.well-known/jwks.json", fst.JWKSHandler(keySource))
+func JWKSHandler(source KeySource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		document, err := MarshalJWKS(source.VerificationKeys())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(document)
+	})
+}
+
+// DefaultJWKSTimeout is the timeout of the default http.Client of the KeySet.
+const DefaultJWKSTimeout = time.Second * 10
+
+// keyRefresher is implemented by the KeySources that can fetch the new keys when a token is not verified by the known ones.
+type keyRefresher interface {
+	// refreshUnknown fetches the keys and reports whether they were changed. It must limit the rate of the fetches,
+	// because anyone can send a token with an invalid signature.
+	refreshUnknown(ctx context.Context) bool
+}
+
+// KeySetConfig represents the configuration options for creating a new KeySet.
+//
+// URL is the URL of the JWKS document.
+//
+// Client is the http.Client used to fetch the document. It is the client with the DefaultJWKSTimeout by default.
+//
+// RefreshInterval is how often the document is fetched. It is one hour by default.
+//
+// MinRefreshInterval is the minimum time between the fetches caused by unknown keys. It is one minute by default.
+//
+// OnError is called when the document can not be fetched. The previous keys stay in use.
+type KeySetConfig struct {
+	// URL is the URL of the JWKS document, like https://auth.example.com/.well-known/jwks.json.
+	URL string
+	// Client is the http.Client used to fetch the document. It is the client with the DefaultJWKSTimeout by default.
+	// The fetches caused by the unknown keys use the context of ParseTokenContext, that is often context.Background(),
+	// so the Client must have a timeout.
+	Client *http.Client
+	// RefreshInterval is how often the document is fetched in the background. It is one hour by default.
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between the fetches caused by the tokens and the key IDs
+	// that are not verified by the known keys. It is one minute by default.
+	MinRefreshInterval time.Duration
+	// OnError is called when the document can not be fetched. The previous keys stay in use.
+	OnError func(err error)
+}
+
+// KeySet is a KeySource that fetches the Ed25519 public keys from a JWKS document, like the one served by JWKSHandler,
+// and caches them. It fetches the document again in the background and when a token is signed with an unknown key,
+// so the verifiers accept the tokens of a rotated key at once.
+//
+// KeySet has no signing key, so the Converter with it can only verify tokens.
+//
+// # Example:
+//
+//	keySet, err := fst.NewKeySet(&fst.KeySetConfig{
+//		URL: "https://auth.example.com/.well-known/jwks.json",
+//		OnError: func(err error) {
+//			log.Println(err)
+//		},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer keySet.Close()
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		Algorithm: fst.AlgorithmEd25519,
+//		KeySource: keySet,
+//	})
+//
+//	value, err := converter.ParseToken(token)
+type KeySet struct {
+	cfg KeySetConfig
+
+	keys atomic.Pointer[[]*Key]
+
+	// mu serializes the fetches.
+	mu sync.Mutex
+	// lastRefresh is the Unix nanoseconds of the last fetch. It is read without the mu,
+	// so the parsers do not wait for the running fetch.
+	lastRefresh atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewKeySet creates a new instance of the KeySet based on the provided fst.KeySetConfig.
+// It fetches the keys and starts refreshing them. Call Close to stop refreshing.
+//
+// It can return InvalidJWKS or the error of the http.Client.
+//
+// An example of usage can be found at KeySet.
+func NewKeySet(cfg *KeySetConfig) (*KeySet, error) {
+	s := &KeySet{
+		cfg:  *cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if s.cfg.Client == nil {
+		s.cfg.Client = &http.Client{Timeout: DefaultJWKSTimeout}
+	}
+
+	if s.cfg.RefreshInterval <= 0 {
+		s.cfg.RefreshInterval = time.Hour
+	}
+
+	if s.cfg.MinRefreshInterval <= 0 {
+		s.cfg.MinRefreshInterval = time.Minute
+	}
+
+	if err := s.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// SigningKey returns nil, because the KeySet has only the public keys.
+func (s *KeySet) SigningKey() *Key {
+	return nil
+}
+
+// VerificationKeys returns the keys of the last fetched document.
+func (s *KeySet) VerificationKeys() []*Key {
+	return *s.keys.Load()
+}
+
+// Key returns the key with the id. If the key is unknown, it fetches the document again,
+// but not more often than the MinRefreshInterval. It returns nil if the key is still unknown.
+// It is useful with the tokens that name their key, like the JWTs with the kid header.
+func (s *KeySet) Key(id string) *Key {
+	if key := s.findKey(id); key != nil {
+		return key
+	}
+
+	if !s.refreshUnknown(context.Background()) {
+		return nil
+	}
+
+	return s.findKey(id)
+}
+
+func (s *KeySet) findKey(id string) *Key {
+	for _, key := range s.VerificationKeys() {
+		if key.ID == id {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// Refresh fetches the document and replaces the keys.
+//
+// It can return InvalidJWKS or the error of the http.Client. The previous keys stay in use on error.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.fetch(ctx)
+	return err
+}
+
+// refreshUnknown fetches the document if the last fetch was earlier than the MinRefreshInterval.
+// It does not wait for the running fetch, so the parsers are not blocked by a slow server.
+func (s *KeySet) refreshUnknown(ctx context.Context) bool {
+	if !s.refreshAllowed() || !s.mu.TryLock() {
+		return false
+	}
+	defer s.mu.Unlock()
+
+	// The fetch could have been made while the lock was being taken.
+	if !s.refreshAllowed() {
+		return false
+	}
+
+	changed, err := s.fetch(ctx)
+	if err != nil && s.cfg.OnError != nil {
+		s.cfg.OnError(err)
+	}
+
+	return changed
+}
+
+func (s *KeySet) refreshAllowed() bool {
+	return time.Since(time.Unix(0, s.lastRefresh.Load())) >= s.cfg.MinRefreshInterval
+}
+
+// fetch fetches the document and reports whether the keys were changed. It must be called with the locked mu.
+func (s *KeySet) fetch(ctx context.Context) (bool, error) {
+	// The failed fetches are also counted, so an unavailable server is not flooded with requests.
+	s.lastRefresh.Store(time.Now().UnixNano())
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+	response, err := s.cfg.Client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, errors.New("fst: JWKS request failed with status " + response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxJWKSSize))
+	if err != nil {
+		return false, err
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return false, err
+	}
+
+	old := s.keys.Load()
+	s.keys.Store(&keys)
+
+	return old == nil || !sameKeys(*old, keys), nil
+}
+
+// Close stops refreshing the keys. The KeySet can still be used after Close with the last fetched keys.
+func (s *KeySet) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+func (s *KeySet) watch() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Refresh(context.Background()); err != nil && s.cfg.OnError != nil {
+				s.cfg.OnError(err)
+			}
+		}
+	}
+}
+
+// sameKeys reports whether a and b have the same keys in the same order.
+func sameKeys(a, b []*Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].ID != b[i].ID || !a[i].PublicKey().Equal(b[i].PublicKey()) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,271 @@
+package fst
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestEd25519Key(t *testing.T, id string) *Key {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewEd25519Key(id, privateKey)
+}
+
+func TestEd25519(t *testing.T) {
+	key := newTestEd25519Key(t, "1")
+	converter := NewConverter(&ConverterConfig{
+		Algorithm:      AlgorithmEd25519,
+		KeySource:      NewStaticKeySource(key),
+		ExpirationTime: time.Hour,
+		SignatureSize:  MinSignatureSize,
+	})
+
+	token, err := converter.NewTokenContext(context.Background(), []byte(`token`))
+	if err != nil {
+		t.Fatal("Token create err: ", err)
+	}
+
+	value, err := converter.ParseToken(token)
+	if err != nil || string(value) != "token" {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	verifier := NewConverter(&ConverterConfig{
+		Algorithm:      AlgorithmEd25519,
+		KeySource:      NewStaticKeySource(nil, NewEd25519VerificationKey("1", key.PublicKey())),
+		ExpirationTime: time.Hour,
+	})
+
+	if _, err = verifier.ParseToken(token); err != nil {
+		t.Fatal("Token parse by the public key err: ", err)
+	}
+
+	if _, err = verifier.NewTokenContext(context.Background(), []byte(`token`)); !errors.Is(err, VerificationOnly) {
+		t.Fatal("Verifier token create err: ", err)
+	}
+	if verifier.NewToken([]byte(`token`)) != nil {
+		t.Fatal("Verifier created a token")
+	}
+
+	token[len(token)-1] ^= 1
+	if _, err = verifier.ParseToken(token); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Forged token parse err: ", err)
+	}
+
+	if _, err = converter.NewSegmentedToken(nil, []byte(`private`)); !errors.Is(err, SymmetricKeyRequired) {
+		t.Fatal("Segmented token create err: ", err)
+	}
+
+	var stream bytes.Buffer
+	if err = converter.SignStream(&stream, bytes.NewReader([]byte(`data`))); !errors.Is(err, SymmetricKeyRequired) {
+		t.Fatal("Stream sign err: ", err)
+	}
+
+	// The secret-less HMAC must not accept the Ed25519 keys.
+	hmacConverter := NewConverter(&ConverterConfig{
+		Algorithm:          AlgorithmEd25519,
+		AcceptedAlgorithms: []Algorithm{AlgorithmHMAC},
+		KeySource:          NewStaticKeySource(key),
+	})
+	forged := NewConverter(&ConverterConfig{
+		AcceptedAlgorithms: []Algorithm{AlgorithmEd25519},
+	}).NewToken([]byte(`token`))
+	if _, err = hmacConverter.ParseToken(forged); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Token with empty secret parse err: ", err)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	source := NewStaticKeySource(newTestEd25519Key(t, "1"), NewKey("secret", []byte(`secret`), nil))
+
+	recorder := httptest.NewRecorder()
+	JWKSHandler(source).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/jwk-set+json" {
+		t.Fatal("JWKS response is ", recorder.Code, recorder.Header())
+	}
+
+	if bytes.Contains(recorder.Body.Bytes(), []byte(`secret`)) {
+		t.Fatal("JWKS contains the symmetric key: ", recorder.Body.String())
+	}
+
+	keys, err := ParseJWKS(recorder.Body.Bytes())
+	if err != nil {
+		t.Fatal("JWKS parse err: ", err)
+	}
+	if len(keys) != 1 || keys[0].ID != "1" || !keys[0].PublicKey().Equal(source.SigningKey().PublicKey()) {
+		t.Fatal("JWKS keys are ", keys)
+	}
+
+	recorder = httptest.NewRecorder()
+	JWKSHandler(source).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatal("JWKS POST status is ", recorder.Code)
+	}
+
+	if _, err = ParseJWKS([]byte(`{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`)); !errors.Is(err, InvalidJWKS) {
+		t.Fatal("JWKS without Ed25519 keys parse err: ", err)
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		source   = NewStaticKeySource(newTestEd25519Key(t, "1"))
+		requests atomic.Int32
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		mu.Lock()
+		current := source
+		mu.Unlock()
+
+		JWKSHandler(current).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	keySet, err := NewKeySet(&KeySetConfig{
+		URL:                server.URL,
+		MinRefreshInterval: time.Millisecond * 100,
+	})
+	if err != nil {
+		t.Fatal("KeySet create err: ", err)
+	}
+	defer keySet.Close()
+
+	verifier := NewConverter(&ConverterConfig{
+		Algorithm: AlgorithmEd25519,
+		KeySource: keySet,
+	})
+
+	newToken := func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return NewConverter(&ConverterConfig{
+			Algorithm: AlgorithmEd25519,
+			KeySource: source,
+		}).NewToken([]byte(`token`))
+	}
+
+	if _, err = verifier.ParseToken(newToken()); err != nil {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	// The key is rotated, the old one is still published.
+	mu.Lock()
+	source = NewStaticKeySource(newTestEd25519Key(t, "2"), source.SigningKey())
+	mu.Unlock()
+
+	time.Sleep(time.Millisecond * 100)
+
+	// The batches fetch the unknown keys like ParseToken.
+	if results := verifier.ParseTokens([][]byte{newToken()}); results[0].Err != nil {
+		t.Fatal("Token of the new key batch parse err: ", results[0].Err)
+	}
+	if _, err = verifier.ParseToken(newToken()); err != nil {
+		t.Fatal("Token of the new key parse err: ", err)
+	}
+	if len(keySet.VerificationKeys()) != 2 {
+		t.Fatal("KeySet is not refreshed")
+	}
+
+	// The unknown keys do not flood the server.
+	before := requests.Load()
+	forged := NewConverter(&ConverterConfig{
+		Algorithm: AlgorithmEd25519,
+		KeySource: NewStaticKeySource(newTestEd25519Key(t, "3")),
+	}).NewToken([]byte(`token`))
+	for range 10 {
+		if _, err = verifier.ParseToken(forged); !errors.Is(err, InvalidSignature) {
+			t.Fatal("Token of an unknown key parse err: ", err)
+		}
+	}
+	if requests.Load()-before > 1 {
+		t.Fatal("KeySet fetched the document ", requests.Load()-before, " times")
+	}
+
+	if keySet.Key("2") == nil || keySet.Key("3") != nil {
+		t.Fatal("KeySet.Key returned unexpected keys")
+	}
+}
+
+func TestKeySet_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := NewKeySet(&KeySetConfig{URL: server.URL}); err == nil {
+		t.Fatal("KeySet is created without the document")
+	}
+}
+
+func TestKeySet_SlowServer(t *testing.T) {
+	var (
+		source   = NewStaticKeySource(newTestEd25519Key(t, "1"))
+		requests atomic.Int32
+		release  = make(chan struct{})
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			<-release
+		}
+
+		JWKSHandler(source).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	keySet, err := NewKeySet(&KeySetConfig{
+		URL:                server.URL,
+		MinRefreshInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal("KeySet create err: ", err)
+	}
+	defer keySet.Close()
+
+	verifier := NewConverter(&ConverterConfig{
+		Algorithm: AlgorithmEd25519,
+		KeySource: keySet,
+	})
+	forged := NewConverter(&ConverterConfig{
+		Algorithm: AlgorithmEd25519,
+		KeySource: NewStaticKeySource(newTestEd25519Key(t, "2")),
+	}).NewToken([]byte(`token`))
+
+	// The first parser waits for the slow fetch.
+	go verifier.ParseToken(forged)
+	for requests.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Others do not wait for it.
+	done := make(chan error, 1)
+	go func() {
+		_, err := verifier.ParseToken(forged)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if !errors.Is(err, InvalidSignature) {
+			t.Fatal("Token parse during the fetch err: ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ParseToken waits for the running fetch")
+	}
+}
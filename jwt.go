@@ -0,0 +1,370 @@
+package fst
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// InvalidJWT means that the JWT is malformed, forged or is not valid yet.
+var InvalidJWT = errors.New("fst: invalid jwt")
+
+// ExpirationNotSupported means that the claims have an expiration time, but the Converter does not use ExpirationTime,
+// so the FST would never expire.
+var ExpirationNotSupported = errors.New("fst: converter does not support the expiration time of the claims")
+
+// JWT algorithms supported by the JWTBridge.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// JWTClaims are the claims of a JWT that can be mapped onto a FST: the subject is the value of the FST,
+// the time of issue and the expiration time are the timestamp of the FST and its ExpirationTime.
+type JWTClaims struct {
+	// Subject is the "sub" claim and the value of the FST.
+	Subject string
+	// IssuedAt is the "iat" claim. It is zero if the token has no time of issue.
+	IssuedAt time.Time
+	// ExpiresAt is the "exp" claim. It is zero if the token never expires.
+	ExpiresAt time.Time
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtPayload struct {
+	Subject   string `json:"sub"`
+	IssuedAt  *int64 `json:"iat,omitempty"`
+	ExpiresAt *int64 `json:"exp,omitempty"`
+	NotBefore *int64 `json:"nbf,omitempty"`
+}
+
+// JWTBridge converts the JWTs to FSTs and vice versa. It is useful to migrate from JWT: during the migration
+// the services can accept both formats with Parse and re-issue the JWTs as FSTs.
+//
+// Only the "sub", "iat", "exp" and "nbf" claims are used, the other claims are ignored.
+//
+// # Example:
+//
+//	bridge := fst.NewJWTBridge(&fst.JWTBridgeConfig{
+//		Converter: fst.NewEncodedConverter(&fst.ConverterConfig{
+//			SecretKey:      []byte(`secret`),
+//			ExpirationTime: time.Hour,
+//		}),
+//		HMACKey: []byte(`jwt secret`),
+//	})
+//
+//	token, err := bridge.JWTToFST(jwt)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//
+//	claims, err := bridge.Parse(tokenOrJWT)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(claims.Subject)
+type JWTBridge struct {
+	converter *EncodedConverter
+
+	hmacKey           []byte
+	ed25519PublicKey  ed25519.PublicKey
+	ed25519PrivateKey ed25519.PrivateKey
+	signingAlgorithm  string
+}
+
+// JWTBridgeConfig represents the configuration options for creating a new JWTBridge.
+//
+// Converter is the EncodedConverter of the FSTs.
+//
+// HMACKey is the key of the HS256 JWTs.
+//
+// Ed25519PublicKey is the key that verifies the EdDSA JWTs.
+//
+// Ed25519PrivateKey is the key that signs the EdDSA JWTs.
+//
+// SigningAlgorithm is the algorithm of the JWTs created by the JWTBridge.
+type JWTBridgeConfig struct {
+	// Converter is the EncodedConverter of the FSTs.
+	Converter *EncodedConverter
+	// HMACKey is the key of the HS256 JWTs. If it is nil, the HS256 JWTs are rejected.
+	HMACKey []byte
+	// Ed25519PublicKey is the key that verifies the EdDSA JWTs. If it is nil, the public key
+	// of the Ed25519PrivateKey is used. If both are nil, the EdDSA JWTs are rejected.
+	Ed25519PublicKey ed25519.PublicKey
+	// Ed25519PrivateKey is the key that signs the EdDSA JWTs.
+	Ed25519PrivateKey ed25519.PrivateKey
+	// SigningAlgorithm is the algorithm of the JWTs created by the JWTBridge: JWTAlgorithmHS256 or JWTAlgorithmEdDSA.
+	// It is JWTAlgorithmHS256 by default if the HMACKey is set, otherwise JWTAlgorithmEdDSA.
+	SigningAlgorithm string
+}
+
+// NewJWTBridge creates a new instance of the JWTBridge based on the provided fst.JWTBridgeConfig.
+//
+// An example of usage can be found at JWTBridge.
+func NewJWTBridge(cfg *JWTBridgeConfig) *JWTBridge {
+	if cfg.Converter == nil {
+		panic("fst: JWTBridgeConfig.Converter is nil")
+	}
+
+	bridge := &JWTBridge{
+		converter:         cfg.Converter,
+		hmacKey:           cfg.HMACKey,
+		ed25519PublicKey:  cfg.Ed25519PublicKey,
+		ed25519PrivateKey: cfg.Ed25519PrivateKey,
+		signingAlgorithm:  cfg.SigningAlgorithm,
+	}
+
+	if bridge.ed25519PublicKey == nil && bridge.ed25519PrivateKey != nil {
+		bridge.ed25519PublicKey = bridge.ed25519PrivateKey.Public().(ed25519.PublicKey)
+	}
+
+	if bridge.signingAlgorithm == "" {
+		bridge.signingAlgorithm = JWTAlgorithmEdDSA
+		if bridge.hmacKey != nil {
+			bridge.signingAlgorithm = JWTAlgorithmHS256
+		}
+	}
+
+	switch {
+	case bridge.signingAlgorithm == JWTAlgorithmHS256 && bridge.hmacKey != nil:
+	case bridge.signingAlgorithm == JWTAlgorithmEdDSA && bridge.ed25519PrivateKey != nil:
+	case bridge.hmacKey == nil && bridge.ed25519PublicKey == nil:
+		panic("fst: JWTBridgeConfig must have HMACKey or Ed25519 keys")
+	default:
+		// The bridge can only verify the JWTs.
+		bridge.signingAlgorithm = ""
+	}
+
+	return bridge
+}
+
+// ParseJWT verifies the JWT and returns its claims.
+//
+// It can return InvalidJWT, UnsupportedAlgorithm or TokenExpired.
+func (b *JWTBridge) ParseJWT(jwt string) (JWTClaims, error) {
+	var claims JWTClaims
+
+	headerEnd := strings.IndexByte(jwt, '.')
+	payloadEnd := strings.LastIndexByte(jwt, '.')
+	if headerEnd < 0 || payloadEnd == headerEnd {
+		return claims, InvalidJWT
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(jwt[:headerEnd])
+	if err != nil {
+		return claims, InvalidJWT
+	}
+
+	var header jwtHeader
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		return claims, InvalidJWT
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(jwt[payloadEnd+1:])
+	if err != nil {
+		return claims, InvalidJWT
+	}
+
+	signed := []byte(jwt[:payloadEnd])
+	switch {
+	case header.Algorithm == JWTAlgorithmHS256 && b.hmacKey != nil:
+		if !hmac.Equal(signature, b.sumHS256(signed)) {
+			return claims, InvalidJWT
+		}
+	case header.Algorithm == JWTAlgorithmEdDSA && b.ed25519PublicKey != nil:
+		if !ed25519.Verify(b.ed25519PublicKey, signed, signature) {
+			return claims, InvalidJWT
+		}
+	default:
+		// It also rejects the "none" algorithm.
+		return claims, UnsupportedAlgorithm
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(jwt[headerEnd+1 : payloadEnd])
+	if err != nil {
+		return claims, InvalidJWT
+	}
+
+	var payload jwtPayload
+	if err = json.Unmarshal(payloadJSON, &payload); err != nil {
+		return claims, InvalidJWT
+	}
+
+	now := b.converter.converter.clock()
+	if payload.NotBefore != nil && time.Unix(*payload.NotBefore, 0).After(now) {
+		return claims, InvalidJWT
+	}
+
+	claims.Subject = payload.Subject
+	if payload.IssuedAt != nil {
+		claims.IssuedAt = time.Unix(*payload.IssuedAt, 0)
+	}
+	if payload.ExpiresAt != nil {
+		claims.ExpiresAt = time.Unix(*payload.ExpiresAt, 0)
+		if claims.ExpiresAt.Before(now) {
+			return claims, TokenExpired
+		}
+	}
+
+	return claims, nil
+}
+
+func (b *JWTBridge) sumHS256(signed []byte) []byte {
+	mac := hmac.New(sha256.New, b.hmacKey)
+	mac.Write(signed)
+
+	return mac.Sum(nil)
+}
+
+// NewJWT creates a new JWT with the claims, signed with the SigningAlgorithm.
+//
+// It returns UnsupportedAlgorithm if the JWTBridge has no key to sign the JWTs.
+func (b *JWTBridge) NewJWT(claims JWTClaims) (string, error) {
+	var header string
+	switch b.signingAlgorithm {
+	case JWTAlgorithmHS256:
+		header = `{"alg":"HS256","typ":"JWT"}`
+	case JWTAlgorithmEdDSA:
+		header = `{"alg":"EdDSA","typ":"JWT"}`
+	default:
+		return "", UnsupportedAlgorithm
+	}
+
+	payload := jwtPayload{Subject: claims.Subject}
+	if !claims.IssuedAt.IsZero() {
+		iat := claims.IssuedAt.Unix()
+		payload.IssuedAt = &iat
+	}
+	if !claims.ExpiresAt.IsZero() {
+		exp := claims.ExpiresAt.Unix()
+		payload.ExpiresAt = &exp
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	var signature []byte
+	if b.signingAlgorithm == JWTAlgorithmHS256 {
+		signature = b.sumHS256([]byte(signed))
+	} else {
+		signature = ed25519.Sign(b.ed25519PrivateKey, []byte(signed))
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWTToFST verifies the JWT and creates the FST with its claims. The value of the FST is the subject.
+//
+// The timestamp of the FST is the time of issue of the JWT. If the FST would outlive the JWT, because the ExpirationTime
+// of the Converter is longer than the lifetime of the JWT, the timestamp is moved back, so the FST expires with the JWT.
+// If the Converter does not use ExpirationTime, only the JWTs without the expiration time can be converted.
+//
+// It can return the errors of ParseJWT and NewFST.
+func (b *JWTBridge) JWTToFST(jwt string) (string, error) {
+	claims, err := b.ParseJWT(jwt)
+	if err != nil {
+		return "", err
+	}
+
+	return b.NewFST(claims)
+}
+
+// NewFST creates the FST with the claims like JWTToFST, but without a JWT.
+//
+// It returns ExpirationNotSupported if the claims have the expiration time, but the Converter does not use ExpirationTime,
+// and TokenExpired if the claims are already expired. It can also return the error of the RemoteSigner.
+func (b *JWTBridge) NewFST(claims JWTClaims) (string, error) {
+	c := b.converter.converter
+	now := c.clock()
+
+	if !claims.ExpiresAt.IsZero() {
+		if !c.expires {
+			return "", ExpirationNotSupported
+		}
+
+		if claims.ExpiresAt.Before(now) {
+			return "", TokenExpired
+		}
+	}
+
+	issuedAt := claims.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = now
+	}
+	if !claims.ExpiresAt.IsZero() && issuedAt.Add(c.expirationTime).After(claims.ExpiresAt) {
+		issuedAt = claims.ExpiresAt.Add(-c.expirationTime)
+	}
+
+	token, err := c.newTokenWithMeta(context.Background(), c.newMeta(issuedAt), []byte(claims.Subject))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+// ParseFST parses the FST and returns its claims. The expiration time is zero if the Converter does not use ExpirationTime.
+//
+// It can return the errors of EncodedConverter.ParseToken.
+func (b *JWTBridge) ParseFST(token string) (JWTClaims, error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return JWTClaims{}, err
+	}
+
+	c := b.converter.converter
+	f, err := c.parseVerifiedFrame(context.Background(), decodedToken, c.now())
+	if err != nil {
+		return JWTClaims{}, err
+	}
+
+	claims := JWTClaims{Subject: string(f.payload)}
+	if c.expires {
+		claims.IssuedAt = f.issuedAt
+		claims.ExpiresAt = c.expiresAt(f.issuedAt, f.timeEncoding)
+	}
+
+	return claims, nil
+}
+
+// FSTToJWT verifies the FST and creates the JWT with its claims, see ParseFST.
+//
+// It can return the errors of ParseFST and NewJWT.
+func (b *JWTBridge) FSTToJWT(token string) (string, error) {
+	claims, err := b.ParseFST(token)
+	if err != nil {
+		return "", err
+	}
+
+	return b.NewJWT(claims)
+}
+
+// Parse detects whether the token is a JWT or a FST and parses it. The JWTs are detected by their
+// base64 encoded JSON header and the two dots, that are never present in the FSTs encoded by EncodedConverter.
+//
+// It can return the errors of ParseJWT or ParseFST.
+func (b *JWTBridge) Parse(token string) (JWTClaims, error) {
+	if IsJWT(token) {
+		return b.ParseJWT(token)
+	}
+
+	return b.ParseFST(token)
+}
+
+// IsJWT reports whether the token looks like a JWT in the compact serialization. It does not verify the token.
+func IsJWT(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
@@ -0,0 +1,203 @@
+package fst
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"sync"
+	"sync/atomic"
+)
+
+// Key represents a secret key that can be used to sign and verify tokens.
+//
+// Use NewKey to create it.
+type Key struct {
+	// ID is the identifier of the key. It is used only for the user's needs, like logging.
+	ID string
+
+	// secret is the copy of the secret in the guarded memory. It is zeroed by Close and is freed when the Key is collected.
+	secret []byte
+	// macs has the mac of AlgorithmEd25519 for the asymmetric keys. The symmetric keys have the symmetric macs instead.
+	macs      [algorithmCount]mac
+	symmetric *macSet
+	// aead is nil for the asymmetric keys.
+	aead       cipher.AEAD
+	hashType   func() hash.Hash
+	asymmetric bool
+
+	// guarded owns the memory of the secret.
+	guarded   *guardedMemory
+	closed    atomic.Bool
+	closeOnce sync.Once
+
+	derived [purposeCount]derivedMacs
+}
+
+// Purposes of the derived keys. Each purpose has its own key, so the signatures of one purpose
+// can never be used for another one, like a chunk signature as a token signature.
+const (
+	purposeStream = iota
+	purposeCSRF
+
+	purposeCount
+)
+
+var purposeLabels = [purposeCount]string{
+	purposeStream: "fst: stream key",
+	purposeCSRF:   "fst: csrf key",
+}
+
+type derivedMacs struct {
+	once sync.Once
+	// guarded owns the memory of the derived secret.
+	guarded *guardedMemory
+	macs    *macSet
+}
+
+// NewKey creates a new Key with the provided id and secret. HashType is the hash function used by AlgorithmHMAC.
+// If hashType is nil, sha256.New is used.
+// The secret is copied into the memory that is locked in RAM and excluded from the core dumps on Linux,
+// so the caller can zero its slice. Close zeroes the copy.
+func NewKey(id string, secret []byte, hashType func() hash.Hash) *Key {
+	guarded := newGuardedMemory(secret)
+
+	return &Key{
+		ID:        id,
+		secret:    guarded.mem,
+		symmetric: newMacSet(hashType, guarded.mem),
+		aead:      newSegmentsAEAD(guarded.mem),
+		hashType:  hashType,
+		guarded:   guarded,
+	}
+}
+
+// Close zeroes the secret and the states derived from it, see the note about the guarded memory.
+// The Key can not sign or verify tokens after Close. The operations that run during Close fail with KeyClosed
+// or reject the tokens, but never return the signatures of the zeroed key, so Close should be called after
+// the operations with the Key finish, like after http.Server.Shutdown. The Key that is not closed is zeroed
+// when it is collected by the GC.
+//
+// The Converter closes only the key that it creates from the ConverterConfig.SecretKey.
+// The keys of the KeySources are closed by their owners.
+func (k *Key) Close() {
+	k.closeOnce.Do(func() {
+		k.closed.Store(true)
+
+		for _, m := range k.macs {
+			if z, ok := m.(zeroer); ok {
+				z.zero()
+			}
+		}
+		if k.symmetric != nil {
+			k.symmetric.zero()
+		}
+
+		for i := range k.derived {
+			// The derived macs are created once, the Do also waits for the running creation.
+			derived := &k.derived[i]
+			derived.once.Do(func() {})
+			if derived.macs != nil {
+				derived.macs.zero()
+				derived.guarded.zero()
+			}
+		}
+
+		// The fields are not cleared, because the running operations can read them. The closed flag stops the new ones,
+		// and the guarded memory is only zeroed, so it is unmapped by the finalizer, when nothing reads it.
+		if k.guarded != nil {
+			k.guarded.zero()
+		}
+	})
+}
+
+// isClosed reports whether the Key has been closed.
+func (k *Key) isClosed() bool {
+	return k.closed.Load()
+}
+
+// derivedMac returns the mac of the algorithm with the key derived from the secret for the purpose.
+// The macs are created on the first use, because most keys are used only for the tokens.
+// It returns nil for the asymmetric keys and for AlgorithmEd25519, because they have no secret.
+func (k *Key) derivedMac(purpose int, algorithm Algorithm) mac {
+	if k == nil || k.asymmetric || k.isClosed() {
+		return nil
+	}
+
+	derived := &k.derived[purpose]
+	derived.once.Do(func() {
+		kdf := hmac.New(sha256.New, k.secret)
+		kdf.Write([]byte(purposeLabels[purpose]))
+		secret := kdf.Sum(nil)
+		derived.guarded = newGuardedMemory(secret)
+		clear(secret)
+		derived.macs = newMacSet(k.hashType, derived.guarded.mem)
+	})
+
+	if derived.macs == nil {
+		return nil
+	}
+
+	return derived.macs.get(algorithm)
+}
+
+// algorithmMac returns the mac of the algorithm or nil if the Key does not support it.
+func (k *Key) algorithmMac(algorithm Algorithm) mac {
+	if k.symmetric != nil {
+		return k.symmetric.get(algorithm)
+	}
+
+	return k.macs[algorithm]
+}
+
+// KeySource provides keys for a Converter. It allows to rotate keys without recreating the Converter.
+//
+// All methods must be safe for concurrent use.
+type KeySource interface {
+	// SigningKey returns the key used to sign new tokens.
+	SigningKey() *Key
+	// VerificationKeys returns all keys accepted by ParseToken, including the signing key.
+	// The most likely keys should go first, because ParseToken checks the keys in the returned order.
+	VerificationKeys() []*Key
+}
+
+// StaticKeySource is a KeySource with the fixed keys. It is useful with the Ed25519 keys,
+// that can not be created from the ConverterConfig.SecretKey.
+//
+// # Example:
+//
+//	_, privateKey, _ := ed25519.GenerateKey(nil)
+//	signingKey := fst.NewEd25519Key("2024-06", privateKey)
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		Algorithm: fst.AlgorithmEd25519,
+//		KeySource: fst.NewStaticKeySource(signingKey),
+//	})
+type StaticKeySource struct {
+	signing      *Key
+	verification []*Key
+}
+
+// NewStaticKeySource creates a new StaticKeySource that signs with the signingKey and verifies with it and the verificationKeys.
+// The signingKey can be nil, then the Converter can only verify tokens.
+func NewStaticKeySource(signingKey *Key, verificationKeys ...*Key) *StaticKeySource {
+	verification := make([]*Key, 0, len(verificationKeys)+1)
+	if signingKey != nil {
+		verification = append(verification, signingKey)
+	}
+
+	return &StaticKeySource{
+		signing:      signingKey,
+		verification: append(verification, verificationKeys...),
+	}
+}
+
+// SigningKey returns the key used to sign new tokens.
+func (s *StaticKeySource) SigningKey() *Key {
+	return s.signing
+}
+
+// VerificationKeys returns the signing key and the other verification keys.
+func (s *StaticKeySource) VerificationKeys() []*Key {
+	return s.verification
+}
@@ -0,0 +1,288 @@
+package fst
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+// The log values never contain the secrets and the values of the tokens. The tokens are logged as their fingerprints,
+// that allow to find the same token in the logs, but not to use it. The secret keys are never fingerprinted,
+// because the hash of a weak secret allows to guess it offline.
+
+// TokenFingerprint returns a short fingerprint of the token: the first 8 bytes of its SHA-256 in hex.
+func TokenFingerprint(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LogToken returns the slog.LogValuer of the token that shows only its fingerprint, algorithm, times
+// and the ID of the key that verifies it, but never its value.
+//
+// # Example:
+//
+//	logger.Info("request", "token", converter.LogToken(token))
+//	// token.fingerprint=1f2e3d4c5b6a7980 token.algorithm=HMAC-SHA256 token.key_id=2024-06 token.expires_at=...
+func (c *Converter) LogToken(token []byte) slog.LogValuer {
+	return loggedToken{converter: c, token: token}
+}
+
+// LogToken returns the slog.LogValuer of the base64 encoded token, like Converter.LogToken.
+func (c *EncodedConverter) LogToken(token string) slog.LogValuer {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return loggedToken{converter: c.converter, token: []byte(token), invalid: true}
+	}
+
+	return loggedToken{converter: c.converter, token: decodedToken}
+}
+
+type loggedToken struct {
+	converter *Converter
+	token     []byte
+	invalid   bool
+}
+
+// LogValue returns the group of the token attributes.
+func (t loggedToken) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs, slog.String("fingerprint", TokenFingerprint(t.token)))
+
+	info, err := t.converter.Inspect(t.token)
+	if t.invalid || err != nil {
+		return slog.GroupValue(append(attrs, slog.String("error", ReasonInvalidFormat.String()))...)
+	}
+
+	attrs = append(attrs, slog.String("algorithm", info.Algorithm.String()))
+
+	if info.HasTimestamp {
+		attrs = append(attrs, slog.Time("issued_at", info.IssuedAt), slog.Time("expires_at", info.ExpiresAt))
+	}
+
+	if keyID, ok := t.converter.verifyingKeyID(t.token); ok {
+		attrs = append(attrs, slog.String("key_id", keyID))
+	} else if t.converter.signer == nil {
+		attrs = append(attrs, slog.Bool("valid", false))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// verifyingKeyID returns the ID of the key that verifies the signature of the token, regardless of its expiration time.
+// It returns false if the token is invalid or the Converter uses a RemoteSigner.
+func (c *Converter) verifyingKeyID(token []byte) (string, bool) {
+	if c.signer != nil {
+		return "", false
+	}
+
+	f, err := c.decodeFrame(token)
+	if err != nil || int(f.algorithm) >= algorithmCount || !c.accepted[f.algorithm] {
+		return "", false
+	}
+
+	if c.keySource == nil {
+		return "", c.verify(c.key, &f)
+	}
+
+	for _, key := range c.keySource.VerificationKeys() {
+		if c.verify(key, &f) {
+			return key.ID, true
+		}
+	}
+
+	return "", false
+}
+
+// LogValue returns the group of the Converter settings without the keys and the postfix.
+func (c *Converter) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs, slog.String("algorithm", c.algorithm.String()))
+
+	if c.expires {
+		attrs = append(attrs, slog.Duration("expiration_time", c.expirationTime))
+	}
+
+	if c.signatureSize != 0 {
+		attrs = append(attrs, slog.Int("signature_size", c.signatureSize))
+	}
+
+	switch {
+	case c.signer != nil:
+		attrs = append(attrs, slog.Bool("remote_signer", true))
+	case c.keySource != nil:
+		if key := c.keySource.SigningKey(); key != nil {
+			attrs = append(attrs, slog.String("signing_key_id", key.ID))
+		}
+		attrs = append(attrs, slog.Int("verification_keys", len(c.keySource.VerificationKeys())))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue returns the group of the Converter settings, like Converter.LogValue.
+func (c *EncodedConverter) LogValue() slog.Value {
+	return c.converter.LogValue()
+}
+
+// LogValue returns the group of the key ID and type. The Ed25519 keys also have the fingerprint of the public key.
+// The secret is never logged.
+func (k *Key) LogValue() slog.Value {
+	if publicKey := k.PublicKey(); publicKey != nil {
+		return slog.GroupValue(
+			slog.String("id", k.ID),
+			slog.String("type", "ed25519"),
+			slog.String("public_key_fingerprint", TokenFingerprint(publicKey)),
+		)
+	}
+
+	return slog.GroupValue(slog.String("id", k.ID), slog.String("type", "symmetric"))
+}
+
+// ParseError is the error of the token parsing with the token, that is logged as its fingerprint and the reason,
+// so the rejected tokens can be logged safely.
+//
+// # Example:
+//
+//	value, err := converter.ParseToken(token)
+//	if err != nil {
+//		logger.Warn("rejected token", "err", &fst.ParseError{Err: err, Token: token})
+//	}
+type ParseError struct {
+	// Err is the error of the parsing, like InvalidSignature.
+	Err error
+	// Token is the rejected token.
+	Token []byte
+}
+
+// Error returns the message of the Err.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the Err.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// LogValue returns the group of the reason, the error message and the fingerprint of the token.
+func (e *ParseError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("reason", reasonOf(e.Err).String()),
+		slog.String("error", e.Err.Error()),
+		slog.String("token_fingerprint", TokenFingerprint(e.Token)),
+	)
+}
+
+// AuditAction is the action reported to the AuditHook.
+type AuditAction uint8
+
+const (
+	// AuditTokenIssued means that a token has been issued.
+	AuditTokenIssued AuditAction = iota
+	// AuditSessionRevoked means that a session of the ReferenceConverter has been revoked.
+	AuditSessionRevoked
+)
+
+// String returns the name of the action: "token_issued" or "session_revoked".
+func (a AuditAction) String() string {
+	switch a {
+	case AuditTokenIssued:
+		return "token_issued"
+	case AuditSessionRevoked:
+		return "session_revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// AuditEvent is the event reported to the AuditHook.
+type AuditEvent struct {
+	// Action is the action.
+	Action AuditAction
+	// Time is the time of the action.
+	Time time.Time
+	// KeyID is the ID of the key that signed the issued token. It is empty for the revocations.
+	KeyID string
+	// Fingerprint is the TokenFingerprint of the issued token or of the ID of the revoked session.
+	Fingerprint string
+	// ExpiresAt is the expiration time of the issued token. It is zero if the token does not expire.
+	ExpiresAt time.Time
+}
+
+// LogValue returns the group of the event attributes.
+func (e AuditEvent) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 5)
+	attrs = append(attrs,
+		slog.String("action", e.Action.String()),
+		slog.Time("time", e.Time),
+		slog.String("fingerprint", e.Fingerprint),
+	)
+
+	if e.KeyID != "" {
+		attrs = append(attrs, slog.String("key_id", e.KeyID))
+	}
+
+	if !e.ExpiresAt.IsZero() {
+		attrs = append(attrs, slog.Time("expires_at", e.ExpiresAt))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// AuditHook is notified about the issued tokens and the revoked sessions, see ConverterConfig.Audit.
+// It is called synchronously, so it must be fast and must be safe for concurrent use.
+type AuditHook interface {
+	Audit(ctx context.Context, e AuditEvent)
+}
+
+// AuditLogger is the AuditHook that writes the events to the slog.Logger.
+//
+// # Example:
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		SecretKey: []byte(`secret`),
+//		Audit:     fst.NewAuditLogger(slog.Default()),
+//	})
+type AuditLogger struct {
+	logger *slog.Logger
+}
+
+// NewAuditLogger creates a new AuditLogger that writes the events to the logger with the slog.LevelInfo.
+func NewAuditLogger(logger *slog.Logger) *AuditLogger {
+	return &AuditLogger{logger: logger}
+}
+
+// Audit writes the event to the logger.
+func (l *AuditLogger) Audit(ctx context.Context, e AuditEvent) {
+	l.logger.LogAttrs(ctx, slog.LevelInfo, "fst audit", slog.Any("event", e))
+}
+
+func (c *Converter) auditIssued(ctx context.Context, token []byte, keyID string) {
+	e := AuditEvent{
+		Action:      AuditTokenIssued,
+		Time:        c.clock(),
+		KeyID:       keyID,
+		Fingerprint: TokenFingerprint(token),
+	}
+
+	if info, err := c.Inspect(token); err == nil && info.HasTimestamp && c.expires {
+		e.ExpiresAt = info.ExpiresAt
+	}
+
+	c.audit.Audit(ctx, e)
+}
+
+func (c *Converter) auditRevoked(ctx context.Context, sessionID []byte) {
+	if c.audit == nil {
+		return
+	}
+
+	c.audit.Audit(ctx, AuditEvent{
+		Action:      AuditSessionRevoked,
+		Time:        c.clock(),
+		Fingerprint: TokenFingerprint(sessionID),
+	})
+}
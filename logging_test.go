@@ -0,0 +1,121 @@
+package fst
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(slog.NewJSONHandler(&buf, nil)), &buf
+}
+
+func TestLogValues(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	key := NewKey("key 1", []byte(`top secret`), nil)
+	converter := NewEncodedConverter(&ConverterConfig{
+		KeySource:      NewStaticKeySource(key),
+		ExpirationTime: time.Hour,
+	})
+
+	token := converter.NewToken([]byte(`private value`))
+	_, err := converter.ParseToken(token[:len(token)-4])
+
+	logger.Info("test",
+		"converter", converter,
+		"key", key,
+		"token", converter.LogToken(token),
+		"invalid", converter.LogToken("!"),
+		"err", &ParseError{Err: err, Token: []byte(token)},
+	)
+
+	if strings.Contains(buf.String(), "top secret") || strings.Contains(buf.String(), "private value") {
+		t.Fatal("Log contains the secrets: ", buf.String())
+	}
+
+	var record struct {
+		Converter map[string]any
+		Key       map[string]any
+		Token     map[string]any
+		Invalid   map[string]any
+		Err       map[string]any
+	}
+	if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Converter["signing_key_id"] != "key 1" || record.Converter["expiration_time"] == nil {
+		t.Error("Converter is logged as ", record.Converter)
+	}
+	if record.Key["id"] != "key 1" || record.Key["type"] != "symmetric" || len(record.Key) != 2 {
+		t.Error("Key is logged as ", record.Key)
+	}
+	if record.Token["key_id"] != "key 1" || record.Token["expires_at"] == nil || len(record.Token["fingerprint"].(string)) != 16 {
+		t.Error("Token is logged as ", record.Token)
+	}
+	if record.Invalid["error"] != "invalid_format" {
+		t.Error("Invalid token is logged as ", record.Invalid)
+	}
+	if record.Err["reason"] == "ok" || record.Err["token_fingerprint"] == nil {
+		t.Error("ParseError is logged as ", record.Err)
+	}
+
+	if !errors.Is(&ParseError{Err: InvalidSignature}, InvalidSignature) {
+		t.Error("ParseError does not unwrap")
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	signer := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Hour,
+		Audit:          NewAuditLogger(logger),
+	})
+	converter := NewReferenceConverter(&ReferenceConverterConfig{
+		Converter: signer,
+		Store:     NewMemorySessionStore(&MemorySessionStoreConfig{}),
+	})
+
+	token, err := converter.NewToken([]byte(`user 1`))
+	if err != nil {
+		t.Fatal("Token create err: ", err)
+	}
+
+	if tokens := signer.NewTokens([][]byte{[]byte(`user 2`)}); len(tokens) != 1 {
+		t.Fatal("Batch create returned ", len(tokens), " tokens")
+	}
+
+	if err = converter.Revoke(token); err != nil {
+		t.Fatal("Revoke err: ", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal("Audit log is ", buf.String())
+	}
+
+	for i, action := range []string{"token_issued", "token_issued", "session_revoked"} {
+		var record struct {
+			Event struct {
+				Action      string
+				Fingerprint string
+				ExpiresAt   *time.Time `json:"expires_at"`
+			}
+		}
+		if err = json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatal(err)
+		}
+
+		if record.Event.Action != action || record.Event.Fingerprint == "" || (action == "token_issued") != (record.Event.ExpiresAt != nil) {
+			t.Error("Audit event ", i, " is ", lines[i])
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package fst
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding"
+	"hash"
+)
+
+// equalPrefix reports in constant time whether the signature is the prefix of the sum.
+// The caller must check that the signature is not shorter than allowed.
+func equalPrefix(signature, sum []byte) bool {
+	if len(signature) == 0 || len(signature) > len(sum) {
+		return false
+	}
+
+	return hmac.Equal(signature, sum[:len(signature)])
+}
+
+// macEngine computes HMAC of the messages.
+//
+// HMAC is H(key ^ opad, H(key ^ ipad, message)). Both keyed states are computed once in newMacEngine
+// and are restored with encoding.BinaryUnmarshaler for each message, so we do not process the pads every time.
+// If the hash does not support encoding.BinaryMarshaler, the engine falls back to the pooled crypto/hmac.
+type macEngine struct {
+	size int
+
+	innerState []byte
+	outerState []byte
+	statePool  statePool
+
+	hmacPool statePool
+	// hmacKey is the copy of the secret for the crypto/hmac fallback.
+	hmacKey []byte
+}
+
+type macState struct {
+	engine *macEngine
+	inner  stateHash
+	outer  stateHash
+	// buf is the scratch for the inner sum and the sum that is compared by verify.
+	buf []byte
+}
+
+type stateHash interface {
+	hash.Hash
+	encoding.BinaryUnmarshaler
+}
+
+func newMacEngine(hashType func() hash.Hash, secret []byte) *macEngine {
+	if hashType == nil {
+		hashType = sha256.New
+	}
+
+	engine := &macEngine{}
+
+	inner, innerOk := hashType().(stateHash)
+	outer, outerOk := hashType().(stateHash)
+	_, marshalerOk := inner.(encoding.BinaryMarshaler)
+	if !innerOk || !outerOk || !marshalerOk {
+		// The secret is copied, because it can be in the guarded memory that is zeroed by Key.Close.
+		engine.hmacKey = append([]byte(nil), secret...)
+		engine.hmacPool.new = func() interface{} {
+			return hmac.New(hashType, engine.hmacKey)
+		}
+		// The crypto/hmac states can not be zeroed, so they are only dropped.
+		engine.hmacPool.zero = func(interface{}) {}
+		engine.size = hmac.New(hashType, engine.hmacKey).Size()
+
+		return engine
+	}
+
+	engine.size = inner.Size()
+	blockSize := inner.BlockSize()
+
+	key := secret
+	if len(key) > blockSize {
+		inner.Write(key)
+		key = inner.Sum(nil)
+		inner.Reset()
+		defer clear(key)
+	}
+
+	ipad := make([]byte, blockSize)
+	opad := make([]byte, blockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+
+	inner.Write(ipad)
+	outer.Write(opad)
+	clear(ipad)
+	clear(opad)
+
+	engine.innerState, _ = inner.(encoding.BinaryMarshaler).MarshalBinary()
+	engine.outerState, _ = outer.(encoding.BinaryMarshaler).MarshalBinary()
+	engine.statePool.zero = func(x interface{}) {
+		state := x.(*macState)
+		state.inner.Reset()
+		state.outer.Reset()
+		clear(state.buf[:cap(state.buf)])
+	}
+	engine.statePool.new = func() interface{} {
+		return &macState{
+			engine: engine,
+			inner:  hashType().(stateHash),
+			outer:  hashType().(stateHash),
+			buf:    make([]byte, 0, 2*engine.size),
+		}
+	}
+
+	return engine
+}
+
+// Size returns the size of the signature.
+func (e *macEngine) Size() int {
+	return e.size
+}
+
+// sum appends the HMAC of payload || meta || postfix to the dst and returns the resulting slice.
+func (e *macEngine) sum(dst, payload, meta, postfix []byte) []byte {
+	if e.innerState == nil {
+		mac := e.hmacPool.get().(hash.Hash)
+		mac.Reset()
+		mac.Write(payload)
+		mac.Write(meta)
+		mac.Write(postfix)
+		dst = mac.Sum(dst)
+		e.hmacPool.put(mac)
+
+		return dst
+	}
+
+	state := e.statePool.get().(*macState)
+	dst = e.sumWithState(state, dst, payload, meta, postfix)
+	e.statePool.put(state)
+
+	return dst
+}
+
+func (e *macEngine) sumWithState(state *macState, dst, payload, meta, postfix []byte) []byte {
+	state.inner.UnmarshalBinary(e.innerState)
+	state.inner.Write(payload)
+	state.inner.Write(meta)
+	state.inner.Write(postfix)
+	state.buf = state.inner.Sum(state.buf[:0])
+
+	state.outer.UnmarshalBinary(e.outerState)
+	state.outer.Write(state.buf)
+
+	return state.outer.Sum(dst)
+}
+
+// verify reports whether the signature is the HMAC of payload || meta || postfix
+// or its prefix for the truncated signatures. It does not allocate. It returns false after zero.
+func (e *macEngine) verify(signature, payload, meta, postfix []byte) bool {
+	if e.innerState == nil {
+		return equalPrefix(signature, e.sum(nil, payload, meta, postfix)) && !e.isZeroed()
+	}
+
+	state := e.statePool.get().(*macState)
+	ok := state.verify(signature, payload, meta, postfix)
+	e.statePool.put(state)
+
+	return ok
+}
+
+// isZeroed reports whether the engine has been zeroed, so its sums are not the HMAC of the secret.
+func (e *macEngine) isZeroed() bool {
+	return e.statePool.isClosed() || e.hmacPool.isClosed()
+}
+
+// acquire returns the state from the pool as a macSession.
+func (e *macEngine) acquire() macSession {
+	if e.innerState == nil {
+		return &hmacSession{
+			engine: e,
+			mac:    e.hmacPool.get().(hash.Hash),
+		}
+	}
+
+	return e.statePool.get().(*macState)
+}
+
+func (s *macState) sum(dst, payload, meta, postfix []byte) []byte {
+	return s.engine.sumWithState(s, dst, payload, meta, postfix)
+}
+
+func (s *macState) verify(signature, payload, meta, postfix []byte) bool {
+	s.buf = s.engine.sumWithState(s, s.buf[:0], payload, meta, postfix)
+
+	return equalPrefix(signature, s.buf) && !s.engine.isZeroed()
+}
+
+func (s *macState) release() {
+	s.engine.statePool.put(s)
+}
+
+// hmacSession is the macSession of the engine that falls back to crypto/hmac.
+type hmacSession struct {
+	engine *macEngine
+	mac    hash.Hash
+	buf    []byte
+}
+
+func (s *hmacSession) sum(dst, payload, meta, postfix []byte) []byte {
+	s.mac.Reset()
+	s.mac.Write(payload)
+	s.mac.Write(meta)
+	s.mac.Write(postfix)
+
+	return s.mac.Sum(dst)
+}
+
+func (s *hmacSession) verify(signature, payload, meta, postfix []byte) bool {
+	s.buf = s.sum(s.buf[:0], payload, meta, postfix)
+
+	return equalPrefix(signature, s.buf) && !s.engine.isZeroed()
+}
+
+func (s *hmacSession) release() {
+	s.engine.hmacPool.put(s.mac)
+}
+
+// zero zeroes the keyed states and the pooled states. The engine still works after it, but its sums are not valid
+// and verify rejects all signatures. The pooled crypto/hmac states can not be zeroed, so they are only dropped.
+func (e *macEngine) zero() {
+	if e.innerState == nil {
+		e.hmacPool.close()
+		clear(e.hmacKey)
+
+		return
+	}
+
+	e.statePool.close()
+	clear(e.innerState)
+	clear(e.outerState)
+}
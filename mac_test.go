@@ -0,0 +1,115 @@
+package fst
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type plainHash struct {
+	hash.Hash
+}
+
+func TestMacEngine(t *testing.T) {
+	hashTypes := map[string]func() hash.Hash{
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+		// plainHash hides encoding.BinaryMarshaler, so the engine falls back to crypto/hmac.
+		"fallback": func() hash.Hash { return plainHash{sha256.New()} },
+	}
+	secrets := [][]byte{[]byte(`secret`), []byte(strings.Repeat("s", 200))}
+
+	for name, hashType := range hashTypes {
+		for _, secret := range secrets {
+			engine := newMacEngine(hashType, secret)
+
+			mac := hmac.New(hashType, secret)
+			mac.Write([]byte(`payloadextimepostfix`))
+			expected := mac.Sum(nil)
+
+			actual := engine.sum(nil, []byte(`payload`), []byte(`extime`), []byte(`postfix`))
+			if !bytes.Equal(expected, actual) {
+				t.Fatal(name, ": signature is not HMAC")
+			}
+
+			if engine.Size() != len(expected) {
+				t.Fatal(name, ": unexpected size ", engine.Size())
+			}
+
+			if !engine.verify(expected, []byte(`payload`), []byte(`extime`), []byte(`postfix`)) {
+				t.Fatal(name, ": valid signature is rejected")
+			}
+
+			if engine.verify(expected, []byte(`payload`), nil, []byte(`postfix`)) {
+				t.Fatal(name, ": invalid signature is accepted")
+			}
+		}
+	}
+}
+
+var benchmarkPayload = []byte("1")
+
+func BenchmarkMac_Precomputed(b *testing.B) {
+	engine := newMacEngine(sha256.New, []byte(`key1`))
+	signature := make([]byte, 0, engine.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		signature = engine.sum(signature[:0], benchmarkPayload, nil, nil)
+	}
+}
+
+func BenchmarkMac_Pool(b *testing.B) {
+	pool := sync.Pool{
+		New: func() interface{} {
+			return hmac.New(sha256.New, []byte(`key1`))
+		},
+	}
+	signature := make([]byte, 0, sha256.Size)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mac := pool.Get().(hash.Hash)
+		mac.Reset()
+		mac.Write(benchmarkPayload)
+		signature = mac.Sum(signature[:0])
+		pool.Put(mac)
+	}
+}
+
+func BenchmarkMacVerify_Precomputed(b *testing.B) {
+	engine := newMacEngine(sha256.New, []byte(`key1`))
+	signature := engine.sum(nil, benchmarkPayload, nil, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		engine.verify(signature, benchmarkPayload, nil, nil)
+	}
+}
+
+func BenchmarkMacVerify_Pool(b *testing.B) {
+	pool := sync.Pool{
+		New: func() interface{} {
+			return hmac.New(sha256.New, []byte(`key1`))
+		},
+	}
+	signature := hmac.New(sha256.New, []byte(`key1`)).Sum(nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mac := pool.Get().(hash.Hash)
+		mac.Reset()
+		mac.Write(benchmarkPayload)
+		hmac.Equal(signature, mac.Sum(nil))
+		pool.Put(mac)
+	}
+}
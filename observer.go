@@ -0,0 +1,147 @@
+package fst
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Operation is the operation of the Converter reported to the Observer.
+type Operation uint8
+
+const (
+	// OperationNewToken is the creation of a token.
+	OperationNewToken Operation = iota
+	// OperationParseToken is the parsing of a token.
+	OperationParseToken
+)
+
+// String returns the name of the operation: "new_token" or "parse_token".
+func (o Operation) String() string {
+	switch o {
+	case OperationNewToken:
+		return "new_token"
+	case OperationParseToken:
+		return "parse_token"
+	default:
+		return "unknown"
+	}
+}
+
+// Reason is the outcome of the operation reported to the Observer.
+type Reason uint8
+
+const (
+	// ReasonOK means that the operation succeeded.
+	ReasonOK Reason = iota
+	// ReasonInvalidFormat means InvalidTokenFormat.
+	ReasonInvalidFormat
+	// ReasonInvalidSignature means InvalidSignature.
+	ReasonInvalidSignature
+	// ReasonExpired means TokenExpired.
+	ReasonExpired
+	// ReasonUnsupportedAlgorithm means UnsupportedAlgorithm.
+	ReasonUnsupportedAlgorithm
+	// ReasonVerificationOnly means VerificationOnly.
+	ReasonVerificationOnly
+	// ReasonOther means other errors, like the errors of the RemoteSigner. It is the last Reason.
+	ReasonOther
+)
+
+// String returns the name of the reason, like "ok" or "invalid_signature", that can be used as a metric label.
+func (r Reason) String() string {
+	switch r {
+	case ReasonOK:
+		return "ok"
+	case ReasonInvalidFormat:
+		return "invalid_format"
+	case ReasonInvalidSignature:
+		return "invalid_signature"
+	case ReasonExpired:
+		return "expired"
+	case ReasonUnsupportedAlgorithm:
+		return "unsupported_algorithm"
+	case ReasonVerificationOnly:
+		return "verification_only"
+	default:
+		return "other"
+	}
+}
+
+func reasonOf(err error) Reason {
+	switch {
+	case err == nil:
+		return ReasonOK
+	case errors.Is(err, InvalidTokenFormat):
+		return ReasonInvalidFormat
+	case errors.Is(err, InvalidSignature):
+		return ReasonInvalidSignature
+	case errors.Is(err, TokenExpired):
+		return ReasonExpired
+	case errors.Is(err, UnsupportedAlgorithm):
+		return ReasonUnsupportedAlgorithm
+	case errors.Is(err, VerificationOnly):
+		return ReasonVerificationOnly
+	default:
+		return ReasonOther
+	}
+}
+
+// Event is the operation of the Converter reported to the Observer.
+type Event struct {
+	// Operation is the operation.
+	Operation Operation
+	// Reason is the outcome of the operation.
+	Reason Reason
+	// Err is the error of the operation or nil.
+	Err error
+	// Duration is the latency of the operation.
+	Duration time.Duration
+	// KeyID is the ID of the key that signed or verified the token. It is empty if the key is unknown,
+	// for example, if the token is invalid, or the Converter uses a RemoteSigner or the SecretKey.
+	KeyID string
+	// Algorithm is the algorithm of the Converter.
+	Algorithm Algorithm
+}
+
+// Observer observes the operations of the Converter, see ConverterConfig.Observer.
+// It is called synchronously, so it must be fast and must be safe for concurrent use.
+//
+// The ctx is the context of the operation, like the one of ParseTokenContext, so the Observer can add the events
+// to the spans of the tracer, like OpenTelemetry. It is context.Background() for the methods without the context.
+//
+// # Example:
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		SecretKey: []byte(`secret`),
+//		Observer: fst.ObserverFunc(func(ctx context.Context, e fst.Event) {
+//			span := trace.SpanFromContext(ctx)
+//			span.AddEvent("fst."+e.Operation.String(), trace.WithAttributes(
+//				attribute.String("fst.reason", e.Reason.String()),
+//				attribute.String("fst.key_id", e.KeyID),
+//			))
+//		}),
+//	})
+type Observer interface {
+	Observe(ctx context.Context, e Event)
+}
+
+// ObserverFunc is an adapter to use a function as the Observer.
+type ObserverFunc func(ctx context.Context, e Event)
+
+// Observe calls f(ctx, e).
+func (f ObserverFunc) Observe(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// observe reports the operation that took the duration to the Observer. It must be called only if the Observer is set.
+func (c *Converter) observe(ctx context.Context, operation Operation, duration time.Duration, keyID string, err error) {
+	c.observer.Observe(ctx, Event{
+		Operation: operation,
+		Reason:    reasonOf(err),
+		Err:       err,
+		Duration:  duration,
+		KeyID:     keyID,
+		Algorithm: c.algorithm,
+	})
+}
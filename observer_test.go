@@ -0,0 +1,71 @@
+package fst
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConverter_Observer(t *testing.T) {
+	var events []Event
+	keySource := NewStaticKeySource(NewKey("key 1", []byte(`secret`), nil))
+
+	converter := NewConverter(&ConverterConfig{
+		KeySource:      keySource,
+		ExpirationTime: time.Hour,
+		Observer: ObserverFunc(func(_ context.Context, e Event) {
+			events = append(events, e)
+		}),
+	})
+
+	token := converter.NewToken([]byte(`token`))
+	converter.ParseToken(token)
+	token[len(token)-6] ^= 1
+	converter.ParseToken(token)
+	converter.ParseToken(nil)
+	converter.NewTokens([][]byte{[]byte(`token 1`), []byte(`token 2`)})
+
+	expected := []Event{
+		{Operation: OperationNewToken, Reason: ReasonOK, KeyID: "key 1"},
+		{Operation: OperationParseToken, Reason: ReasonOK, KeyID: "key 1"},
+		{Operation: OperationParseToken, Reason: ReasonInvalidSignature},
+		{Operation: OperationParseToken, Reason: ReasonInvalidFormat},
+		{Operation: OperationNewToken, Reason: ReasonOK, KeyID: "key 1"},
+		{Operation: OperationNewToken, Reason: ReasonOK, KeyID: "key 1"},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatal("Events are ", events)
+	}
+
+	for i, e := range events {
+		if e.Operation != expected[i].Operation || e.Reason != expected[i].Reason || e.KeyID != expected[i].KeyID {
+			t.Error("Event ", i, " is ", e, " expected ", expected[i])
+		}
+		if (e.Reason == ReasonOK) != (e.Err == nil) || e.Duration < 0 {
+			t.Error("Event ", i, " has error ", e.Err, " and duration ", e.Duration)
+		}
+	}
+}
+
+func TestConverter_ObserverAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("The race detector allocates")
+	}
+
+	for _, observer := range []Observer{nil, ObserverFunc(func(context.Context, Event) {})} {
+		converter := NewConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Hour,
+			Observer:       observer,
+		})
+		token := converter.NewToken([]byte(`token`))
+
+		allocs := testing.AllocsPerRun(100, func() {
+			converter.ParseToken(token)
+		})
+		if allocs != 0 {
+			t.Error("ParseToken with the observer ", observer != nil, " allocates ", allocs, " times")
+		}
+	}
+}
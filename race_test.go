@@ -0,0 +1,6 @@
+//go:build race
+
+package fst
+
+// raceEnabled reports whether the tests are built with the race detector, that allocates in the runtime.
+const raceEnabled = true
@@ -0,0 +1,173 @@
+package fst
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+)
+
+// DefaultSessionIDSize is the default size of the random session ID of the reference token.
+const DefaultSessionIDSize = 16
+
+// ReferenceConverter represents a token converter that creates opaque reference tokens. The reference token is
+// a FST with only a random session ID, and the payload is stored in the SessionStore. So the payload never leaves
+// the server and the session can be revoked at any time, but every ParseToken reads the SessionStore.
+//
+// # Example:
+//
+//	converter := fst.NewReferenceConverter(&fst.ReferenceConverterConfig{
+//		Converter: fst.NewConverter(&fst.ConverterConfig{
+//			SecretKey:      []byte(`secret`),
+//			ExpirationTime: time.Hour,
+//		}),
+//		Store: fst.NewMemorySessionStore(&fst.MemorySessionStoreConfig{}),
+//	})
+//
+//	token, err := converter.NewToken([]byte(`user 1, admin`))
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//
+//	value, err := converter.ParseToken(token)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(string(value)) // user 1, admin
+//
+//	err = converter.Revoke(token)
+//	_, err = converter.ParseToken(token) // fst.SessionNotFound
+type ReferenceConverter struct {
+	converter *Converter
+	store     SessionStore
+	idSize    int
+}
+
+// ReferenceConverterConfig represents the configuration options for creating a new ReferenceConverter.
+//
+// Converter is the Converter that signs the session IDs.
+//
+// Store is the SessionStore of the payloads.
+//
+// IDSize is the size of the random session ID. It is DefaultSessionIDSize by default.
+type ReferenceConverterConfig struct {
+	// Converter is the Converter that signs the session IDs. Its ExpirationTime is the lifetime of the sessions.
+	Converter *Converter
+	// Store is the SessionStore of the payloads.
+	Store SessionStore
+	// IDSize is the size of the random session ID in bytes. It is DefaultSessionIDSize by default.
+	IDSize int
+}
+
+// NewReferenceConverter creates a new instance of the ReferenceConverter based on the provided fst.ReferenceConverterConfig.
+//
+// An example of usage can be found at ReferenceConverter.
+func NewReferenceConverter(cfg *ReferenceConverterConfig) *ReferenceConverter {
+	if cfg.Converter == nil || cfg.Store == nil {
+		panic("fst: ReferenceConverterConfig.Converter and ReferenceConverterConfig.Store must be set")
+	}
+
+	converter := &ReferenceConverter{
+		converter: cfg.Converter,
+		store:     cfg.Store,
+		idSize:    cfg.IDSize,
+	}
+
+	if converter.idSize == 0 {
+		converter.idSize = DefaultSessionIDSize
+	}
+
+	return converter
+}
+
+// NewToken stores the value in the SessionStore with a new random ID and returns the signed ID as the token.
+// This method does not encode the token in base64.
+//
+// It can return the error of the SessionStore or the RemoteSigner.
+func (c *ReferenceConverter) NewToken(value []byte) ([]byte, error) {
+	id := make([]byte, c.idSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var expiresAt time.Time
+	if c.converter.expires {
+		expiresAt = c.converter.clock().Add(c.converter.expirationTime)
+	}
+
+	if err := c.store.Store(id, value, expiresAt); err != nil {
+		return nil, err
+	}
+
+	token, err := c.converter.NewTokenContext(context.Background(), id)
+	if err != nil {
+		c.store.Delete(id)
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ParseToken verifies the token and returns the value from the SessionStore.
+// The returned value must not be modified.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, SessionNotFound
+// or the error of the SessionStore.
+func (c *ReferenceConverter) ParseToken(token []byte) ([]byte, error) {
+	id, err := c.SessionID(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.store.Load(id)
+}
+
+// SessionID verifies the token and returns its session ID without reading the SessionStore.
+// It is useful to log the sessions or to list them for the user.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired.
+func (c *ReferenceConverter) SessionID(token []byte) ([]byte, error) {
+	id, err := c.converter.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(id) != c.idSize {
+		return nil, InvalidTokenFormat
+	}
+
+	return id, nil
+}
+
+// Lookup returns the value of the session with the id, like ParseToken, but without the token.
+// It is useful for the administrative tools.
+//
+// It can return SessionNotFound or the error of the SessionStore.
+func (c *ReferenceConverter) Lookup(id []byte) ([]byte, error) {
+	return c.store.Load(id)
+}
+
+// Revoke verifies the token and deletes its session, so the token and all its copies can not be parsed anymore.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired or the error of the SessionStore.
+func (c *ReferenceConverter) Revoke(token []byte) error {
+	id, err := c.SessionID(token)
+	if err != nil {
+		return err
+	}
+
+	return c.RevokeID(id)
+}
+
+// RevokeID deletes the session with the id, like Revoke, but without the token.
+// The revocation is reported to the AuditHook of the Converter.
+//
+// It can return the error of the SessionStore.
+func (c *ReferenceConverter) RevokeID(id []byte) error {
+	if err := c.store.Delete(id); err != nil {
+		return err
+	}
+
+	c.converter.auditRevoked(context.Background(), id)
+
+	return nil
+}
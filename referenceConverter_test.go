@@ -0,0 +1,220 @@
+package fst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testReferenceConverter(t *testing.T, store SessionStore) {
+	converter := NewReferenceConverter(&ReferenceConverterConfig{
+		Converter: NewConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Minute * 5,
+		}),
+		Store: store,
+	})
+
+	token, err := converter.NewToken([]byte(`user 1`))
+	if err != nil {
+		t.Fatal("NewToken err: ", err)
+	}
+
+	if bytes.Contains(token, []byte(`user 1`)) {
+		t.Fatal("Reference token contains the payload")
+	}
+
+	value, err := converter.ParseToken(token)
+	if err != nil || string(value) != "user 1" {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	id, err := converter.SessionID(token)
+	if err != nil {
+		t.Fatal("SessionID err: ", err)
+	}
+	if value, err = converter.Lookup(id); err != nil || string(value) != "user 1" {
+		t.Fatal("Lookup err: ", err)
+	}
+
+	if err = converter.Revoke(token); err != nil {
+		t.Fatal("Revoke err: ", err)
+	}
+	if _, err = converter.ParseToken(token); !errors.Is(err, SessionNotFound) {
+		t.Fatal("Revoked token parse err: ", err)
+	}
+
+	token[len(token)-1] ^= 1
+	if _, err = converter.ParseToken(token); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Forged token parse err: ", err)
+	}
+}
+
+func TestReferenceConverter_MemorySessionStore(t *testing.T) {
+	testReferenceConverter(t, NewMemorySessionStore(&MemorySessionStoreConfig{}))
+}
+
+func TestReferenceConverter_Now(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	cfg := &FileSessionStoreConfig{
+		Path: filepath.Join(t.TempDir(), "sessions"),
+		Now:  now,
+	}
+	store, err := NewFileSessionStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewReferenceConverter(&ReferenceConverterConfig{
+		Converter: NewConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Minute * 5,
+			Now:            now,
+		}),
+		Store: store,
+	})
+
+	token, err := converter.NewToken([]byte(`user 1`))
+	if err != nil {
+		t.Fatal("NewToken err: ", err)
+	}
+
+	// The session expires by the clock of the Converter, so it is live for the store with the same clock.
+	if value, err := converter.ParseToken(token); err != nil || string(value) != "user 1" {
+		t.Fatal("Token parse err: ", err)
+	}
+
+	// Compact and replay keep the session too.
+	if err = store.Compact(); err != nil {
+		t.Fatal("Compact err: ", err)
+	}
+	store.Close()
+
+	if store, err = NewFileSessionStore(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if store.memory.Len() != 1 {
+		t.Fatal("Store has ", store.memory.Len(), " sessions after replay")
+	}
+}
+
+func TestReferenceConverter_FileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(&FileSessionStoreConfig{
+		Path: filepath.Join(t.TempDir(), "sessions"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	testReferenceConverter(t, store)
+}
+
+func TestFileSessionStore_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions")
+	store, err := NewFileSessionStore(&FileSessionStoreConfig{Path: path, Sync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Store([]byte(`live`), []byte(`payload`), time.Time{})
+	store.Store([]byte(`deleted`), []byte(`payload`), time.Time{})
+	store.Store([]byte(`expired`), []byte(`payload`), time.Now().Add(-time.Second))
+	store.Delete([]byte(`deleted`))
+	store.Close()
+
+	// A torn record at the end, like after a crash.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(appendSessionRecord(nil, sessionRecordStore, []byte(`torn`), []byte(`payload`), time.Time{})[:10])
+	file.Close()
+
+	check := func() {
+		if payload, err := store.Load([]byte(`live`)); err != nil || string(payload) != "payload" {
+			t.Fatal("Live session load err: ", err)
+		}
+
+		for _, id := range []string{"deleted", "expired", "torn"} {
+			if _, err := store.Load([]byte(id)); !errors.Is(err, SessionNotFound) {
+				t.Fatal(id, " session load err: ", err)
+			}
+		}
+	}
+
+	if store, err = NewFileSessionStore(&FileSessionStoreConfig{Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	// The torn record is cut off, so the new records are readable.
+	store.Store([]byte(`new`), []byte(`payload`), time.Time{})
+	if err = store.Compact(); err != nil {
+		t.Fatal("Compact err: ", err)
+	}
+	store.Store([]byte(`after compact`), []byte(`payload`), time.Time{})
+	store.Close()
+
+	if store, err = NewFileSessionStore(&FileSessionStoreConfig{Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	check()
+
+	for _, id := range []string{"new", "after compact"} {
+		if _, err = store.Load([]byte(id)); err != nil {
+			t.Fatal(id, " session load err: ", err)
+		}
+	}
+
+	if store.memory.Len() != 3 {
+		t.Fatal("Store has ", store.memory.Len(), " sessions")
+	}
+}
+
+func TestFileSessionStore_CorruptedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions")
+
+	var log []byte
+	log = appendSessionRecord(log, sessionRecordStore, []byte(`first`), []byte(`payload`), time.Time{})
+	corrupted := len(log) + 3
+	log = appendSessionRecord(log, sessionRecordStore, []byte(`second`), []byte(`payload`), time.Time{})
+	log = appendSessionRecord(log, sessionRecordStore, []byte(`third`), []byte(`payload`), time.Time{})
+	log[corrupted] ^= 1
+
+	if err := os.WriteFile(path, log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileSessionStore(&FileSessionStoreConfig{Path: path}); !errors.Is(err, CorruptedSessionLog) {
+		t.Fatal("Corrupted log open err: ", err)
+	}
+
+	// The records after the corrupted one are not cut off.
+	if file, _ := os.ReadFile(path); len(file) != len(log) {
+		t.Fatal("Corrupted log is truncated to ", len(file), " bytes")
+	}
+
+	// The damaged length of the first record runs past the end of the log, but it is not a torn record.
+	log[corrupted] ^= 1
+	log[1] = 0x7f
+	if err := os.WriteFile(path, log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileSessionStore(&FileSessionStoreConfig{Path: path}); !errors.Is(err, CorruptedSessionLog) {
+		t.Fatal("Log with damaged length open err: ", err)
+	}
+
+	if file, _ := os.ReadFile(path); len(file) != len(log) {
+		t.Fatal("Log with damaged length is truncated to ", len(file), " bytes")
+	}
+}
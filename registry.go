@@ -0,0 +1,388 @@
+package fst
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// UnknownTenant means that the TenantConfigProvider has no config for the tenant.
+	// The TenantConfigProvider should return it for the unknown tenants.
+	UnknownTenant = errors.New("fst: unknown tenant")
+	// InvalidTenantID means that the tenant ID is empty or longer than MaxTenantIDSize.
+	InvalidTenantID = errors.New("fst: invalid tenant ID")
+)
+
+// errTenantInvalidated means that the tenant was invalidated while its Converter was being created.
+var errTenantInvalidated = errors.New("fst: tenant invalidated")
+
+const (
+	// MaxTenantIDSize is the maximum size of the tenant ID in bytes.
+	MaxTenantIDSize = 255
+	// DefaultFailureTTL is the default time of caching the errors of the TenantConfigProvider.
+	DefaultFailureTTL = time.Second * 5
+	// DefaultMaxFailures is the default number of the cached errors of the TenantConfigProvider.
+	DefaultMaxFailures = 1024
+)
+
+// The registry token is [1 byte tenant ID length] [tenant ID] [token of the tenant's Converter].
+// The tenant ID is also appended to the postfix of the tenant's Converter, so the token of one tenant
+// can not be presented as the token of another one, even if both tenants have the same secret.
+
+// TenantConfigProvider provides the ConverterConfig of the tenants, for example, from a database.
+//
+// TenantConfig must be safe for concurrent use. It is called once per tenant, until Registry.Invalidate is called.
+// Its errors are cached for the RegistryConfig.FailureTTL, so the unknown tenants don't call it on every token.
+type TenantConfigProvider interface {
+	// TenantConfig returns the ConverterConfig of the tenant or UnknownTenant.
+	TenantConfig(tenantID string) (*ConverterConfig, error)
+}
+
+// TenantConfigProviderFunc is an adapter to use a function as the TenantConfigProvider.
+type TenantConfigProviderFunc func(tenantID string) (*ConverterConfig, error)
+
+// TenantConfig calls f(tenantID).
+func (f TenantConfigProviderFunc) TenantConfig(tenantID string) (*ConverterConfig, error) {
+	return f(tenantID)
+}
+
+// Registry represents the Converters of many tenants, each with its own secret, expiration time and postfix.
+// It creates the Converter of a tenant on the first use with the config from the TenantConfigProvider and caches it.
+// The tokens of the Registry contain the tenant ID, so ParseToken finds the Converter of the token by itself.
+//
+// # Example:
+//
+//	registry := fst.NewRegistry(&fst.RegistryConfig{
+//		Provider: fst.TenantConfigProviderFunc(func(tenantID string) (*fst.ConverterConfig, error) {
+//			tenant, ok := tenants[tenantID]
+//			if !ok {
+//				return nil, fst.UnknownTenant
+//			}
+//
+//			return &fst.ConverterConfig{
+//				SecretKey:      tenant.Secret,
+//				ExpirationTime: tenant.SessionLifetime,
+//			}, nil
+//		}),
+//	})
+//
+//	token, err := registry.NewToken("acme", []byte(`user 1`))
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//
+//	tenantID, value, err := registry.ParseToken(token)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(tenantID, string(value)) // acme user 1
+type Registry struct {
+	provider    TenantConfigProvider
+	failureTTL  time.Duration
+	maxFailures int
+
+	mu sync.RWMutex
+	// tenants has the created Converters and the ones that are being created.
+	tenants map[string]*tenantConverter
+	// failures has the errors of the Provider. They are kept apart from the tenants and are bounded
+	// by the maxFailures, so the tokens with random tenant IDs can not grow the Registry.
+	failures map[string]tenantFailure
+}
+
+type tenantConverter struct {
+	once      sync.Once
+	converter *Converter
+	err       error
+}
+
+type tenantFailure struct {
+	err error
+	// retryAt is the time after which the failed config is requested again.
+	retryAt time.Time
+}
+
+// RegistryConfig represents the configuration options for creating a new Registry.
+//
+// Provider is the TenantConfigProvider of the tenants' configs.
+//
+// FailureTTL is the time of caching the errors of the Provider. It is DefaultFailureTTL by default.
+//
+// MaxFailures is the maximum number of the cached errors of the Provider. It is DefaultMaxFailures by default.
+type RegistryConfig struct {
+	// Provider is the TenantConfigProvider of the tenants' configs.
+	Provider TenantConfigProvider
+	// FailureTTL is the time of caching the errors of the Provider, like UnknownTenant, so the tokens
+	// of the unknown tenants don't reach the Provider on every call. It is DefaultFailureTTL by default.
+	FailureTTL time.Duration
+	// MaxFailures is the maximum number of the cached errors of the Provider. When it is reached,
+	// the expired errors are removed, and if there are none, a random one is. It is DefaultMaxFailures by default.
+	MaxFailures int
+}
+
+// NewRegistry creates a new instance of the Registry based on the provided fst.RegistryConfig.
+//
+// An example of usage can be found at Registry.
+func NewRegistry(cfg *RegistryConfig) *Registry {
+	if cfg.Provider == nil {
+		panic("fst: RegistryConfig.Provider is nil")
+	}
+
+	r := &Registry{
+		provider:    cfg.Provider,
+		failureTTL:  cfg.FailureTTL,
+		maxFailures: cfg.MaxFailures,
+		tenants:     make(map[string]*tenantConverter),
+		failures:    make(map[string]tenantFailure),
+	}
+
+	if r.failureTTL <= 0 {
+		r.failureTTL = DefaultFailureTTL
+	}
+	if r.maxFailures <= 0 {
+		r.maxFailures = DefaultMaxFailures
+	}
+
+	return r
+}
+
+// Converter returns the Converter of the tenant. It creates the Converter on the first call for the tenant.
+// The tokens of the returned Converter are bound to the tenant, but have no tenant ID, so Registry.ParseToken
+// can not parse them. It is useful when the tenant is known from elsewhere, like the host name.
+//
+// It can return InvalidTenantID or the error of the TenantConfigProvider, like UnknownTenant.
+// The errors are cached for the FailureTTL.
+func (r *Registry) Converter(tenantID string) (*Converter, error) {
+	if len(tenantID) == 0 || len(tenantID) > MaxTenantIDSize {
+		return nil, InvalidTenantID
+	}
+
+	for {
+		if err := r.failure(tenantID); err != nil {
+			return nil, err
+		}
+
+		tenant := r.tenant(tenantID)
+		tenant.once.Do(func() {
+			tenant.converter, tenant.err = r.newConverter(tenantID)
+			if tenant.err != nil {
+				r.fail(tenantID, tenant)
+			}
+		})
+
+		if tenant.err == errTenantInvalidated {
+			continue
+		}
+
+		return tenant.converter, tenant.err
+	}
+}
+
+// failure returns the cached error of the tenant or nil if there is none or it is expired.
+func (r *Registry) failure(tenantID string) error {
+	r.mu.RLock()
+	failure, ok := r.failures[tenantID]
+	r.mu.RUnlock()
+
+	if !ok || !time.Now().Before(failure.retryAt) {
+		return nil
+	}
+
+	return failure.err
+}
+
+// tenant returns the cached tenantConverter of the tenant or adds a new one.
+func (r *Registry) tenant(tenantID string) *tenantConverter {
+	r.mu.RLock()
+	tenant := r.tenants[tenantID]
+	r.mu.RUnlock()
+
+	if tenant != nil {
+		return tenant
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tenant = r.tenants[tenantID]; tenant == nil {
+		tenant = &tenantConverter{}
+		r.tenants[tenantID] = tenant
+	}
+
+	return tenant
+}
+
+// fail moves the error of the tenant from the tenants to the failures. The error is not cached
+// if the tenant has been invalidated while its Converter was being created.
+func (r *Registry) fail(tenantID string, tenant *tenantConverter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tenants[tenantID] != tenant {
+		return
+	}
+	delete(r.tenants, tenantID)
+
+	if len(r.failures) >= r.maxFailures {
+		now := time.Now()
+		for id, failure := range r.failures {
+			if !now.Before(failure.retryAt) {
+				delete(r.failures, id)
+			}
+		}
+
+		// The map iteration order is random, so a random failure is removed.
+		for id := range r.failures {
+			if len(r.failures) < r.maxFailures {
+				break
+			}
+			delete(r.failures, id)
+		}
+	}
+
+	r.failures[tenantID] = tenantFailure{
+		err:     tenant.err,
+		retryAt: time.Now().Add(r.failureTTL),
+	}
+}
+
+// EncodedConverter returns the EncodedConverter of the tenant, like Converter.
+func (r *Registry) EncodedConverter(tenantID string) (*EncodedConverter, error) {
+	converter, err := r.Converter(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncodedConverter{converter: converter}, nil
+}
+
+func (r *Registry) newConverter(tenantID string) (*Converter, error) {
+	cfg, err := r.provider.TenantConfig(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg == nil {
+		return nil, UnknownTenant
+	}
+
+	tenantCfg := *cfg
+	tenantCfg.Postfix = make([]byte, 0, len(cfg.Postfix)+len(tenantID)+1)
+	tenantCfg.Postfix = append(tenantCfg.Postfix, cfg.Postfix...)
+	tenantCfg.Postfix = append(tenantCfg.Postfix, byte(len(tenantID)))
+	tenantCfg.Postfix = append(tenantCfg.Postfix, tenantID...)
+
+	return NewConverter(&tenantCfg), nil
+}
+
+// Invalidate removes the cached Converter or the cached error of the tenant, so the next call creates it with the new config.
+// The tokens of the old config are not accepted if the new config has another secret.
+//
+// The removed Converter is not closed, because the running calls can still use it. The Converters of the tenant
+// returned by Converter and EncodedConverter before keep the old config, and the old key is zeroed by the GC
+// when they are not used anymore.
+func (r *Registry) Invalidate(tenantID string) {
+	r.mu.Lock()
+	tenant := r.tenants[tenantID]
+	delete(r.tenants, tenantID)
+	delete(r.failures, tenantID)
+	r.mu.Unlock()
+
+	if tenant == nil {
+		return
+	}
+
+	// If the Converter is not created yet, the callers that got the tenant before it was removed
+	// get errTenantInvalidated and create the Converter with the new config.
+	tenant.once.Do(func() {
+		tenant.err = errTenantInvalidated
+	})
+}
+
+// NewToken creates a new token of the tenant with the provided value. This method does not encode the token in base64.
+//
+// It can return InvalidTenantID, the error of the TenantConfigProvider or the error of the tenant's Converter.
+func (r *Registry) NewToken(tenantID string, value []byte) ([]byte, error) {
+	converter, err := r.Converter(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := converter.NewTokenContext(context.Background(), value)
+	if err != nil {
+		return nil, err
+	}
+
+	registryToken := make([]byte, 0, 1+len(tenantID)+len(token))
+	registryToken = append(registryToken, byte(len(tenantID)))
+	registryToken = append(registryToken, tenantID...)
+
+	return append(registryToken, token...), nil
+}
+
+// ParseToken parses the token with the Converter of its tenant and returns the tenant ID and the value.
+// This method will not copy the token's value.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, UnknownTenant
+// or the error of the TenantConfigProvider.
+func (r *Registry) ParseToken(token []byte) (tenantID string, value []byte, err error) {
+	tenantID, token, err = splitTenantToken(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	converter, err := r.Converter(tenantID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	value, err = converter.ParseToken(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tenantID, value, nil
+}
+
+// NewEncodedToken creates a new token of the tenant like NewToken, but encodes it in base64.
+func (r *Registry) NewEncodedToken(tenantID string, value []byte) (string, error) {
+	token, err := r.NewToken(tenantID, value)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+// ParseEncodedToken parses the base64 encoded token like ParseToken. This method will copy the token's value.
+//
+// It can also return the error of base64 decoding.
+func (r *Registry) ParseEncodedToken(token string) (tenantID string, value []byte, err error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return r.ParseToken(decodedToken)
+}
+
+// TenantID returns the tenant ID of the token without verifying it. It must not be trusted
+// before the token is parsed, but it is useful to route the request or to log it.
+//
+// It can return InvalidTokenFormat.
+func TenantID(token []byte) (string, error) {
+	tenantID, _, err := splitTenantToken(token)
+	return tenantID, err
+}
+
+func splitTenantToken(token []byte) (tenantID string, rest []byte, err error) {
+	if len(token) == 0 || token[0] == 0 || len(token) <= 1+int(token[0]) {
+		return "", nil, InvalidTokenFormat
+	}
+
+	size := 1 + int(token[0])
+
+	return string(token[1:size]), token[size:], nil
+}
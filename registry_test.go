@@ -0,0 +1,175 @@
+package fst
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	var calls atomic.Int32
+	secrets := map[string]string{
+		"acme":   "secret",
+		"globex": "secret",
+	}
+
+	registry := NewRegistry(&RegistryConfig{
+		Provider: TenantConfigProviderFunc(func(tenantID string) (*ConverterConfig, error) {
+			calls.Add(1)
+
+			secret, ok := secrets[tenantID]
+			if !ok {
+				return nil, UnknownTenant
+			}
+
+			return &ConverterConfig{
+				SecretKey:      []byte(secret),
+				ExpirationTime: time.Hour,
+			}, nil
+		}),
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			token, err := registry.NewToken("acme", []byte(`user 1`))
+			if err != nil {
+				t.Error("Token create err: ", err)
+				return
+			}
+
+			tenantID, value, err := registry.ParseToken(token)
+			if err != nil || tenantID != "acme" || string(value) != "user 1" {
+				t.Error("Token parse err: ", err, tenantID, string(value))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatal("TenantConfig is called ", calls.Load(), " times")
+	}
+
+	token, err := registry.NewEncodedToken("acme", []byte(`user 1`))
+	if err != nil {
+		t.Fatal("Encoded token create err: ", err)
+	}
+	if tenantID, value, err := registry.ParseEncodedToken(token); err != nil || tenantID != "acme" || string(value) != "user 1" {
+		t.Fatal("Encoded token parse err: ", err)
+	}
+
+	// The tenants have the same secret, but the token of one tenant is not accepted by another one.
+	acmeToken, _ := registry.NewToken("acme", []byte(`user 1`))
+	forged := append([]byte{6}, "globex"...)
+	forged = append(forged, acmeToken[1+len("acme"):]...)
+
+	if tenantID, _ := TenantID(forged); tenantID != "globex" {
+		t.Fatal("TenantID is ", tenantID)
+	}
+	if _, _, err = registry.ParseToken(forged); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Token of another tenant parse err: ", err)
+	}
+
+	if _, err = registry.NewToken("initech", []byte(`user 1`)); !errors.Is(err, UnknownTenant) {
+		t.Fatal("Token of unknown tenant create err: ", err)
+	}
+	if _, _, err = registry.ParseToken([]byte{0, 1, 2}); !errors.Is(err, InvalidTokenFormat) {
+		t.Fatal("Token without tenant parse err: ", err)
+	}
+
+	// The secret is rotated, the old Converter still works, but its tokens are not accepted.
+	oldConverter, err := registry.Converter("acme")
+	if err != nil {
+		t.Fatal("Converter err: ", err)
+	}
+	secrets["acme"] = "new secret"
+	registry.Invalidate("acme")
+
+	if _, _, err = registry.ParseToken(acmeToken); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Token of the old secret parse err: ", err)
+	}
+	oldToken, err := oldConverter.NewTokenContext(context.Background(), []byte(`user 1`))
+	if err != nil {
+		t.Fatal("Invalidated Converter create err: ", err)
+	}
+	if _, _, err = registry.ParseToken(append([]byte("\x04acme"), oldToken...)); !errors.Is(err, InvalidSignature) {
+		t.Fatal("Token of the invalidated Converter parse err: ", err)
+	}
+}
+
+func TestRegistry_FailureTTL(t *testing.T) {
+	var calls atomic.Int32
+	secrets := map[string]string{}
+
+	newRegistry := func(failureTTL time.Duration) *Registry {
+		calls.Store(0)
+
+		return NewRegistry(&RegistryConfig{
+			Provider: TenantConfigProviderFunc(func(tenantID string) (*ConverterConfig, error) {
+				calls.Add(1)
+
+				secret, ok := secrets[tenantID]
+				if !ok {
+					return nil, UnknownTenant
+				}
+
+				return &ConverterConfig{SecretKey: []byte(secret)}, nil
+			}),
+			FailureTTL: failureTTL,
+		})
+	}
+
+	registry := newRegistry(0)
+	for range 3 {
+		if _, err := registry.Converter("initech"); !errors.Is(err, UnknownTenant) {
+			t.Fatal("Converter of unknown tenant err: ", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatal("TenantConfig of unknown tenant is called ", calls.Load(), " times")
+	}
+
+	// Invalidate removes the cached error.
+	secrets["initech"] = "secret"
+	registry.Invalidate("initech")
+	if _, err := registry.Converter("initech"); err != nil {
+		t.Fatal("Converter of invalidated tenant err: ", err)
+	}
+
+	// The expired error is not returned, the config is requested again.
+	registry = newRegistry(time.Nanosecond)
+	for range 2 {
+		if _, err := registry.Converter("umbrella"); !errors.Is(err, UnknownTenant) {
+			t.Fatal("Converter of unknown tenant err: ", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatal("TenantConfig of unknown tenant is called ", calls.Load(), " times")
+	}
+}
+
+func TestRegistry_MaxFailures(t *testing.T) {
+	registry := NewRegistry(&RegistryConfig{
+		Provider: TenantConfigProviderFunc(func(tenantID string) (*ConverterConfig, error) {
+			return nil, UnknownTenant
+		}),
+		MaxFailures: 100,
+	})
+
+	for i := range 10000 {
+		if _, err := registry.Converter("tenant " + strconv.Itoa(i)); !errors.Is(err, UnknownTenant) {
+			t.Fatal("Converter of unknown tenant err: ", err)
+		}
+	}
+
+	if len(registry.tenants) != 0 || len(registry.failures) > 100 {
+		t.Fatal("Registry has ", len(registry.tenants), " tenants and ", len(registry.failures), " failures")
+	}
+}
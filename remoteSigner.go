@@ -0,0 +1,86 @@
+package fst
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// InvalidSignerResponse means that the RemoteSigner returned a different number of results than the number of messages.
+var InvalidSignerResponse = errors.New("fst: invalid response of the remote signer")
+
+// RemoteSigner signs and verifies tokens without exposing the key to the process, like KMS or HSM.
+//
+// Each message is the data covered by the signature of a token: the payload, the expiration time and the postfix.
+// The calls are batched to limit round-trips, so len(result) must be equal to len(messages),
+// otherwise the Converter returns InvalidSignerResponse.
+//
+// All methods must be safe for concurrent use.
+type RemoteSigner interface {
+	// Sign returns the signatures of the messages.
+	Sign(ctx context.Context, messages [][]byte) ([][]byte, error)
+	// Verify reports for each message whether its signature is valid.
+	Verify(ctx context.Context, messages, signatures [][]byte) ([]bool, error)
+}
+
+// signatureCache is a fixed-size cache of the verified signatures. When it is full, the oldest entry is evicted.
+type signatureCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]struct{}
+	ring    [][sha256.Size]byte
+	next    int
+}
+
+func newSignatureCache(size int) *signatureCache {
+	return &signatureCache{
+		entries: make(map[[sha256.Size]byte]struct{}, size),
+		ring:    make([][sha256.Size]byte, 0, size),
+	}
+}
+
+func signatureCacheKey(message, signature []byte) [sha256.Size]byte {
+	var length [binary.MaxVarintLen64]byte
+
+	h := sha256.New()
+	h.Write(length[:binary.PutUvarint(length[:], uint64(len(signature)))])
+	h.Write(signature)
+	h.Write(message)
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	return key
+}
+
+func (c *signatureCache) contains(message, signature []byte) bool {
+	key := signatureCacheKey(message, signature)
+
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	c.mu.Unlock()
+
+	return ok
+}
+
+func (c *signatureCache) add(message, signature []byte) {
+	key := signatureCacheKey(message, signature)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	if len(c.ring) < cap(c.ring) {
+		c.ring = append(c.ring, key)
+	} else {
+		delete(c.entries, c.ring[c.next])
+		c.ring[c.next] = key
+		c.next = (c.next + 1) % len(c.ring)
+	}
+
+	c.entries[key] = struct{}{}
+}
@@ -0,0 +1,165 @@
+package fst
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingSigner struct {
+	RemoteSigner
+	verifyCalls atomic.Int32
+}
+
+func (s *countingSigner) Verify(ctx context.Context, messages, signatures [][]byte) ([]bool, error) {
+	s.verifyCalls.Add(1)
+	return s.RemoteSigner.Verify(ctx, messages, signatures)
+}
+
+func newTestUnixSigner(t *testing.T) *UnixSigner {
+	// t.TempDir can be too long for a Unix socket path.
+	dir, err := os.MkdirTemp("", "fst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "signer.sock")
+	server, err := ListenUnixSigner(path, NewKey("kms", []byte(`secret`), sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	signer := NewUnixSigner(path)
+	t.Cleanup(func() { signer.Close() })
+
+	return signer
+}
+
+func TestConverter_RemoteSigner(t *testing.T) {
+	signer := &countingSigner{RemoteSigner: newTestUnixSigner(t)}
+
+	converter := NewConverter(&ConverterConfig{
+		Postfix:           []byte(`postfix`),
+		ExpirationTime:    time.Minute * 5,
+		Signer:            signer,
+		VerifiedCacheSize: 16,
+	})
+
+	testWithSize(t, converter, 5)
+	testWithSize(t, converter, 100000)
+
+	token, err := converter.NewTokenContext(context.Background(), []byte(`token`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The remote signer must produce the same tokens as the local key.
+	localConverter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		Postfix:        []byte(`postfix`),
+		ExpirationTime: time.Minute * 5,
+	})
+	if _, err = localConverter.ParseToken(token); err != nil {
+		t.Fatal("token signed remotely is rejected by the local key: ", err)
+	}
+
+	calls := signer.verifyCalls.Load()
+	for i := 0; i < 3; i++ {
+		if _, err = converter.ParseTokenContext(context.Background(), token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if signer.verifyCalls.Load() != calls+1 {
+		t.Fatal("verified signature is not cached: ", signer.verifyCalls.Load()-calls, " calls")
+	}
+
+	forged := append(token, []byte("admin")...)
+	if _, err = converter.ParseToken(forged); !errors.Is(err, InvalidSignature) {
+		t.Fatal("forged token parse err: ", err)
+	}
+}
+
+func TestConverter_RemoteSignerCanceled(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		Signer: newTestUnixSigner(t),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := converter.NewTokenContext(ctx, []byte(`token`)); !errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	if token := converter.NewToken([]byte(`token`)); len(token) == 0 {
+		t.Fatal("token is empty")
+	}
+}
+
+func TestSignatureCache(t *testing.T) {
+	cache := newSignatureCache(2)
+
+	cache.add([]byte("a"), []byte("1"))
+	cache.add([]byte("b"), []byte("2"))
+	cache.add([]byte("c"), []byte("3"))
+
+	if cache.contains([]byte("a"), []byte("1")) {
+		t.Error("the oldest entry is not evicted")
+	}
+
+	if !cache.contains([]byte("b"), []byte("2")) || !cache.contains([]byte("c"), []byte("3")) {
+		t.Error("entries are lost")
+	}
+
+	if cache.contains([]byte("b"), []byte("3")) {
+		t.Error("signature is not a part of the cache key")
+	}
+}
+
+type shortSigner struct{}
+
+func (shortSigner) Sign(context.Context, [][]byte) ([][]byte, error) {
+	return nil, nil
+}
+
+func (shortSigner) Verify(context.Context, [][]byte, [][]byte) ([]bool, error) {
+	return nil, nil
+}
+
+func TestConverter_RemoteSignerInvalidResponse(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		Signer: shortSigner{},
+	})
+
+	if _, err := converter.NewTokenContext(context.Background(), []byte(`token`)); !errors.Is(err, InvalidSignerResponse) {
+		t.Fatal("NewTokenContext err: ", err)
+	}
+	if _, err := converter.NewTokensContext(context.Background(), [][]byte{[]byte(`a`), []byte(`b`)}); !errors.Is(err, InvalidSignerResponse) {
+		t.Fatal("NewTokensContext err: ", err)
+	}
+
+	token := NewConverter(&ConverterConfig{SecretKey: []byte(`secret`)}).NewToken([]byte(`token`))
+	if _, err := converter.ParseToken(token); !errors.Is(err, InvalidSignerResponse) {
+		t.Fatal("ParseToken err: ", err)
+	}
+	for _, result := range converter.ParseTokens([][]byte{token, token}) {
+		if !errors.Is(result.Err, InvalidSignerResponse) {
+			t.Fatal("ParseTokens err: ", result.Err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Signer with SignatureSize does not panic")
+		}
+	}()
+	NewConverter(&ConverterConfig{Signer: shortSigner{}, SignatureSize: MinSignatureSize})
+}
@@ -0,0 +1,351 @@
+package fst
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// InsufficientScope means that the token does not have the scopes required by the check.
+	InsufficientScope = errors.New("fst: insufficient scope")
+	// NoToken means that the request has no token.
+	NoToken = errors.New("fst: no token")
+)
+
+// The value of the scoped token is [scopes] [value], where scopes are either
+// [0] [uvarint N] [N bytes bitset of the KnownScopes] or
+// [1] [uvarint count] count * ([uvarint len] [scope]).
+// The bitset is used when all scopes are known, so a few scopes take a few bytes.
+
+const (
+	scopesBitset = 0
+	scopesList   = 1
+)
+
+// Scopes are the scopes of a token, like "files:read" or "billing:*".
+// The scope that ends with ":*" implies all scopes with its prefix, so "files:*" implies "files:read"
+// and "files:read:own", and the "*" scope implies all scopes.
+type Scopes []string
+
+// Has reports whether the scopes imply the scope.
+func (s Scopes) Has(scope string) bool {
+	for _, granted := range s {
+		if scopeImplies(granted, scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireScopes returns nil if the scopes imply all required scopes, otherwise InsufficientScope.
+func (s Scopes) RequireScopes(required ...string) error {
+	for _, scope := range required {
+		if !s.Has(scope) {
+			return InsufficientScope
+		}
+	}
+
+	return nil
+}
+
+// RequireAny returns nil if the scopes imply at least one of the required scopes, otherwise InsufficientScope.
+func (s Scopes) RequireAny(required ...string) error {
+	for _, scope := range required {
+		if s.Has(scope) {
+			return nil
+		}
+	}
+
+	return InsufficientScope
+}
+
+func scopeImplies(granted, required string) bool {
+	if granted == required || granted == "*" {
+		return true
+	}
+
+	return strings.HasSuffix(granted, ":*") && strings.HasPrefix(required, granted[:len(granted)-1])
+}
+
+// ScopedConverter represents a token converter that stores the scopes in the token next to the value,
+// so the handlers check the permissions without parsing the value.
+//
+// # Example:
+//
+//	scoped := fst.NewScopedConverter(&fst.ScopedConverterConfig{
+//		Converter:   converter,
+//		KnownScopes: []string{"files:read", "files:write", "files:*", "billing:read"},
+//	})
+//
+//	token := scoped.NewToken([]byte(`user 1`), fst.Scopes{"files:*"})
+//
+//	value, scopes, err := scoped.ParseToken(token)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(scopes.RequireScopes("files:read")) // <nil>
+//
+//	mux.Handle("/files/", scoped.RequireScopes("files:read")(filesHandler))
+//	mux.Handle("/billing/", scoped.RequireAny("billing:read", "admin")(billingHandler))
+type ScopedConverter struct {
+	converter        *Converter
+	knownScopes      []string
+	scopeBits        map[string]int
+	tokenFromRequest func(r *http.Request) string
+	onError          func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// ScopedConverterConfig represents the configuration options for creating a new ScopedConverter.
+//
+// Converter is the EncodedConverter of the tokens.
+//
+// KnownScopes are the scopes that are stored as bits. Other scopes are stored as strings.
+//
+// TokenFromRequest returns the token of the request. It reads the "Authorization: Bearer" header by default.
+//
+// OnError is called by the middlewares when the request is rejected.
+// It responds with 401 Unauthorized or with 403 Forbidden for InsufficientScope by default.
+type ScopedConverterConfig struct {
+	// Converter is the EncodedConverter of the tokens.
+	Converter *EncodedConverter
+	// KnownScopes are the scopes that are stored as bits, so the tokens with only these scopes are compact.
+	// The index of a scope is its bit, so the new scopes must only be appended to the end.
+	KnownScopes []string
+	// TokenFromRequest returns the token of the request or an empty string.
+	// It reads the "Authorization: Bearer" header by default.
+	TokenFromRequest func(r *http.Request) string
+	// OnError is called by the middlewares when the request is rejected.
+	// It responds with 401 Unauthorized or with 403 Forbidden for InsufficientScope by default.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewScopedConverter creates a new instance of the ScopedConverter based on the provided fst.ScopedConverterConfig.
+//
+// An example of usage can be found at ScopedConverter.
+func NewScopedConverter(cfg *ScopedConverterConfig) *ScopedConverter {
+	if cfg.Converter == nil {
+		panic("fst: ScopedConverterConfig.Converter is nil")
+	}
+
+	c := &ScopedConverter{
+		converter:        cfg.Converter.converter,
+		knownScopes:      cfg.KnownScopes,
+		scopeBits:        make(map[string]int, len(cfg.KnownScopes)),
+		tokenFromRequest: cfg.TokenFromRequest,
+		onError:          cfg.OnError,
+	}
+
+	for i, scope := range cfg.KnownScopes {
+		c.scopeBits[scope] = i
+	}
+
+	if c.tokenFromRequest == nil {
+		c.tokenFromRequest = bearerToken
+	}
+
+	if c.onError == nil {
+		c.onError = func(w http.ResponseWriter, _ *http.Request, err error) {
+			if errors.Is(err, InsufficientScope) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		}
+	}
+
+	return c
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+
+	return header[len(prefix):]
+}
+
+// NewToken creates a new token with the provided value and scopes. This method encodes the token in base64.
+//
+// If the Converter uses a RemoteSigner, NewToken returns an empty string when the signer fails.
+// Use NewTokenContext to get the error.
+func (c *ScopedConverter) NewToken(value []byte, scopes Scopes) string {
+	token, _ := c.NewTokenContext(context.Background(), value, scopes)
+	return token
+}
+
+// NewTokenContext creates a new token like NewToken, but passes the ctx to the RemoteSigner and returns its error.
+func (c *ScopedConverter) NewTokenContext(ctx context.Context, value []byte, scopes Scopes) (string, error) {
+	scopedValue := c.appendScopes(make([]byte, 0, len(value)+8), scopes)
+	scopedValue = append(scopedValue, value...)
+
+	token, err := c.converter.NewTokenContext(ctx, scopedValue)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+func (c *ScopedConverter) appendScopes(dst []byte, scopes Scopes) []byte {
+	bits := make([]byte, 0, (len(c.knownScopes)+7)/8)
+	for _, scope := range scopes {
+		i, ok := c.scopeBits[scope]
+		if !ok {
+			dst = append(dst, scopesList)
+			dst = binary.AppendUvarint(dst, uint64(len(scopes)))
+			for _, scope = range scopes {
+				dst = binary.AppendUvarint(dst, uint64(len(scope)))
+				dst = append(dst, scope...)
+			}
+
+			return dst
+		}
+
+		for len(bits) <= i/8 {
+			bits = append(bits, 0)
+		}
+		bits[i/8] |= 1 << (i % 8)
+	}
+
+	dst = append(dst, scopesBitset)
+	dst = binary.AppendUvarint(dst, uint64(len(bits)))
+
+	return append(dst, bits...)
+}
+
+// ParseToken parses the token and returns its value and scopes. This method will copy the token's value.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired or the error of base64 decoding.
+func (c *ScopedConverter) ParseToken(token string) (value []byte, scopes Scopes, err error) {
+	return c.ParseTokenContext(context.Background(), token)
+}
+
+// ParseTokenContext parses the token like ParseToken, but passes the ctx to the RemoteSigner.
+func (c *ScopedConverter) ParseTokenContext(ctx context.Context, token string) (value []byte, scopes Scopes, err error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopedValue, err := c.converter.ParseTokenContext(ctx, decodedToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes, n := c.readScopes(scopedValue)
+	if n <= 0 {
+		return nil, nil, InvalidTokenFormat
+	}
+
+	return scopedValue[n:], scopes, nil
+}
+
+// readScopes reads the scopes from the buf and returns them with the number of read bytes.
+// The bits of the unknown scopes are ignored, so the tokens of a newer version with more KnownScopes grant no more than this one knows.
+func (c *ScopedConverter) readScopes(buf []byte) (Scopes, int) {
+	if len(buf) == 0 {
+		return nil, 0
+	}
+
+	count, size := getUvarint(buf[1:])
+	if size <= 0 || count > uint64(len(buf)-1-size) {
+		return nil, 0
+	}
+	offset := 1 + size
+
+	switch buf[0] {
+	case scopesBitset:
+		bits := buf[offset : offset+int(count)]
+		var scopes Scopes
+		for i, scope := range c.knownScopes {
+			if i/8 < len(bits) && bits[i/8]&(1<<(i%8)) != 0 {
+				scopes = append(scopes, scope)
+			}
+		}
+
+		return scopes, offset + int(count)
+	case scopesList:
+		scopes := make(Scopes, 0, count)
+		for range count {
+			scopeLen, size := getUvarint(buf[offset:])
+			if size <= 0 || scopeLen > uint64(len(buf)-offset-size) {
+				return nil, 0
+			}
+			offset += size
+			scopes = append(scopes, string(buf[offset:offset+int(scopeLen)]))
+			offset += int(scopeLen)
+		}
+
+		return scopes, offset
+	default:
+		return nil, 0
+	}
+}
+
+type scopedContextKey struct{}
+
+type scopedContextValue struct {
+	value  []byte
+	scopes Scopes
+}
+
+// ScopedFromContext returns the value and the scopes of the token parsed by the ScopedConverter middlewares.
+// ok is false if the request has not passed through them.
+func ScopedFromContext(ctx context.Context) (value []byte, scopes Scopes, ok bool) {
+	v, ok := ctx.Value(scopedContextKey{}).(*scopedContextValue)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return v.value, v.scopes, true
+}
+
+// Middleware returns a middleware that parses the token of the request and passes it to the next handler
+// if the check returns nil. The value and the scopes of the token can be read with ScopedFromContext.
+func (c *ScopedConverter) Middleware(check func(scopes Scopes) error) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := c.tokenFromRequest(r)
+			if token == "" {
+				c.onError(w, r, NoToken)
+				return
+			}
+
+			value, scopes, err := c.ParseTokenContext(r.Context(), token)
+			if err == nil {
+				err = check(scopes)
+			}
+			if err != nil {
+				c.onError(w, r, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), scopedContextKey{}, &scopedContextValue{value: value, scopes: scopes})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScopes returns a middleware that accepts only the requests whose tokens imply all required scopes.
+func (c *ScopedConverter) RequireScopes(required ...string) func(next http.Handler) http.Handler {
+	return c.Middleware(func(scopes Scopes) error {
+		return scopes.RequireScopes(required...)
+	})
+}
+
+// RequireAny returns a middleware that accepts only the requests whose tokens imply at least one of the required scopes.
+func (c *ScopedConverter) RequireAny(required ...string) func(next http.Handler) http.Handler {
+	return c.Middleware(func(scopes Scopes) error {
+		return scopes.RequireAny(required...)
+	})
+}
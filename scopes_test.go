@@ -0,0 +1,110 @@
+package fst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScopes_Has(t *testing.T) {
+	scopes := Scopes{"files:*", "billing:read"}
+
+	for _, scope := range []string{"files:read", "files:write", "files:read:own", "billing:read"} {
+		if !scopes.Has(scope) {
+			t.Error("Scopes do not have ", scope)
+		}
+	}
+
+	for _, scope := range []string{"files", "filesystem:read", "billing:write", "billing:*"} {
+		if scopes.Has(scope) {
+			t.Error("Scopes have ", scope)
+		}
+	}
+
+	if !(Scopes{"*"}).Has("anything:at:all") {
+		t.Error("* does not imply all scopes")
+	}
+
+	if err := scopes.RequireScopes("files:read", "billing:read"); err != nil {
+		t.Error("RequireScopes err: ", err)
+	}
+	if err := scopes.RequireScopes("files:read", "billing:write"); !errors.Is(err, InsufficientScope) {
+		t.Error("RequireScopes err: ", err)
+	}
+	if err := scopes.RequireAny("admin", "billing:read"); err != nil {
+		t.Error("RequireAny err: ", err)
+	}
+	if err := scopes.RequireAny("admin"); !errors.Is(err, InsufficientScope) {
+		t.Error("RequireAny err: ", err)
+	}
+}
+
+func TestScopedConverter(t *testing.T) {
+	scoped := NewScopedConverter(&ScopedConverterConfig{
+		Converter:   NewEncodedConverter(&ConverterConfig{SecretKey: []byte(`secret`)}),
+		KnownScopes: []string{"files:read", "files:write", "files:*", "billing:read"},
+	})
+
+	for _, scopes := range []Scopes{nil, {"files:*", "billing:read"}, {"files:read", "custom"}} {
+		token := scoped.NewToken([]byte(`user 1`), scopes)
+
+		value, parsed, err := scoped.ParseToken(token)
+		if err != nil {
+			t.Fatal("Token parse err: ", err)
+		}
+		if string(value) != "user 1" || len(parsed) != len(scopes) {
+			t.Fatal("Token value is ", string(value), " scopes are ", parsed)
+		}
+		for i := range scopes {
+			if parsed[i] != scopes[i] {
+				t.Fatal("Scopes are ", parsed, " expected ", scopes)
+			}
+		}
+	}
+
+	// Two known scopes take 3 bytes.
+	if len(scoped.appendScopes(nil, Scopes{"files:*", "billing:read"})) != 3 {
+		t.Fatal("Known scopes are not stored as bits")
+	}
+}
+
+func TestScopedConverter_Middleware(t *testing.T) {
+	scoped := NewScopedConverter(&ScopedConverterConfig{
+		Converter:   NewEncodedConverter(&ConverterConfig{SecretKey: []byte(`secret`)}),
+		KnownScopes: []string{"files:read", "files:*"},
+	})
+
+	handler := scoped.RequireScopes("files:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, scopes, ok := ScopedFromContext(r.Context())
+		if !ok || string(value) != "user 1" || !scopes.Has("files:write") {
+			t.Error("ScopedFromContext returned ", string(value), scopes, ok)
+		}
+	}))
+
+	for _, test := range []struct {
+		scopes Scopes
+		token  string
+		status int
+	}{
+		{scopes: Scopes{"files:*"}, status: http.StatusOK},
+		{scopes: Scopes{"files:read"}, status: http.StatusForbidden},
+		{token: "invalid", status: http.StatusUnauthorized},
+		{status: http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/files/1", nil)
+		token := test.token
+		if test.scopes != nil {
+			token = scoped.NewToken([]byte(`user 1`), test.scopes)
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Error("Status for ", test.scopes, test.token, " is ", w.Code)
+		}
+	}
+}
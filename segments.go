@@ -0,0 +1,238 @@
+package fst
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Segmented token layout:
+// [1 byte header] [timestamp?] [uvarint publicLen] [public] [12 bytes nonce] [encrypted private] [16 bytes tag]
+//
+// The header always has algorithm ID 15 (AES-256-GCM) and the varint framing bit.
+// The timestamp is present only if the time encoding of the header is not zero, and it is always compact.
+// The tag covers the private segment and, as the additional data, everything before the nonce and the postfix.
+
+const (
+	segmentsAlgorithmID = 0x0f
+	segmentsNonceSize   = 12
+	segmentsTagSize     = 16
+)
+
+// SegmentsNotSupported means that the Converter can not create segmented tokens, because it uses a RemoteSigner.
+var SegmentsNotSupported = errors.New("fst: segmented tokens are not supported with a RemoteSigner")
+
+// newSegmentsAEAD creates the AES-256-GCM with the key derived from the secret.
+func newSegmentsAEAD(secret []byte) cipher.AEAD {
+	kdf := hmac.New(sha256.New, secret)
+	kdf.Write([]byte("fst: aes-256-gcm key"))
+
+	block, err := aes.NewCipher(kdf.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return aead
+}
+
+// segmentedFrame is a segmented token split into its parts.
+type segmentedFrame struct {
+	timeEncoding byte
+	issuedAt     time.Time
+	// additionalData is the part of the token before the nonce.
+	additionalData []byte
+	public         []byte
+	nonce          []byte
+	sealed         []byte
+}
+
+func parseSegmentedFrame(token []byte) (segmentedFrame, error) {
+	var f segmentedFrame
+
+	if len(token) == 0 || !isSegmentedHeader(token[0]) {
+		return f, InvalidTokenFormat
+	}
+
+	offset := 1
+	f.timeEncoding = (token[0] & headerTimeMask) >> headerTimeShift
+	if f.timeEncoding != timeEncodingUnixSeconds {
+		if f.timeEncoding >= timeEncodingCount {
+			return f, InvalidTokenFormat
+		}
+
+		issuedAt, n := readTimestamp(token[offset:], f.timeEncoding)
+		if n <= 0 {
+			return f, InvalidTokenFormat
+		}
+
+		f.issuedAt = issuedAt
+		offset += n
+	}
+
+	publicLen, n := getUvarint(token[offset:])
+	if n <= 0 || publicLen > uint64(len(token)) {
+		return f, InvalidTokenFormat
+	}
+	offset += n
+
+	nonceOffset := offset + int(publicLen)
+	if len(token) < nonceOffset+segmentsNonceSize+segmentsTagSize {
+		return f, InvalidTokenFormat
+	}
+
+	f.public = token[offset:nonceOffset]
+	f.additionalData = token[:nonceOffset]
+	f.nonce = token[nonceOffset : nonceOffset+segmentsNonceSize]
+	f.sealed = token[nonceOffset+segmentsNonceSize:]
+
+	return f, nil
+}
+
+// NewSegmentedToken creates a new FST with the public segment, that can be read without the key,
+// and the private segment, that is encrypted with AES-256-GCM. Both segments and the expiration time
+// are covered by the same AEAD tag. This method does not encode the token in base64.
+//
+// The key of the AEAD is derived from the secret key. The nonce is random, so don't create more than 2^32 tokens with one key.
+//
+// It returns SegmentsNotSupported if the Converter uses a RemoteSigner or SymmetricKeyRequired if its key is asymmetric.
+func (c *Converter) NewSegmentedToken(public, private []byte) ([]byte, error) {
+	if c.signer != nil {
+		return nil, SegmentsNotSupported
+	}
+
+	timeEncoding := c.timeEncoding
+	if timeEncoding == timeEncodingUnixSeconds {
+		timeEncoding = timeEncodingSeconds
+	}
+
+	header := byte(segmentsAlgorithmID) | headerVarintFlag
+	if c.expires {
+		header |= timeEncoding << headerTimeShift
+	}
+
+	key := c.signingKey()
+	if key != nil && key.isClosed() {
+		return nil, KeyClosed
+	}
+	if key == nil || key.aead == nil {
+		return nil, SymmetricKeyRequired
+	}
+
+	aead := key.aead
+	token := make([]byte, 0, 1+binary.MaxVarintLen64*2+len(public)+segmentsNonceSize+len(private)+aead.Overhead())
+	token = append(token, header)
+	if c.expires {
+		token = appendTimestamp(token, timeEncoding, c.clock())
+	}
+	token = binary.AppendUvarint(token, uint64(len(public)))
+	token = append(token, public...)
+
+	nonceOffset := len(token)
+	token = token[:nonceOffset+segmentsNonceSize]
+	if _, err := rand.Read(token[nonceOffset:]); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(token, token[nonceOffset:], private, c.additionalData(token[:nonceOffset])), nil
+}
+
+// ParseSegmentedToken parses a segmented FST and returns its public and decrypted private segments.
+// This method will use token to return the public segment instead of copying.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, SegmentsNotSupported
+// and KeyClosed, if the Converter is closed.
+func (c *Converter) ParseSegmentedToken(token []byte) (public, private []byte, err error) {
+	if c.signer != nil {
+		return nil, nil, SegmentsNotSupported
+	}
+
+	f, err := parseSegmentedFrame(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.expires != (f.timeEncoding != timeEncodingUnixSeconds) {
+		return nil, nil, InvalidTokenFormat
+	}
+
+	if c.expires && c.isExpired(f.issuedAt, f.timeEncoding, c.clock()) {
+		return nil, nil, TokenExpired
+	}
+
+	additionalData := c.additionalData(f.additionalData)
+
+	if c.keySource == nil {
+		if c.key.isClosed() {
+			return nil, nil, KeyClosed
+		}
+
+		private, err = c.key.aead.Open(nil, f.nonce, f.sealed, additionalData)
+		if err != nil {
+			return nil, nil, InvalidSignature
+		}
+
+		return f.public, private, nil
+	}
+
+	for _, key := range c.keySource.VerificationKeys() {
+		if key.aead == nil || key.isClosed() {
+			continue
+		}
+
+		if private, err = key.aead.Open(nil, f.nonce, f.sealed, additionalData); err == nil {
+			return f.public, private, nil
+		}
+	}
+
+	return nil, nil, InvalidSignature
+}
+
+// additionalData returns the additional data of the AEAD: the part of the token before the nonce and the postfix.
+func (c *Converter) additionalData(beforeNonce []byte) []byte {
+	if c.postfix == nil {
+		return beforeNonce
+	}
+
+	additionalData := make([]byte, 0, len(beforeNonce)+len(c.postfix))
+	additionalData = append(additionalData, beforeNonce...)
+
+	return append(additionalData, c.postfix...)
+}
+
+// PeekPublicRaw returns the public segment of the segmented FST without the key.
+// It does not verify the token, so the returned segment must not be trusted.
+//
+// It can return InvalidTokenFormat.
+func PeekPublicRaw(token []byte) ([]byte, error) {
+	f, err := parseSegmentedFrame(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.public, nil
+}
+
+// PeekPublic returns the public segment of the segmented FST, encoded by EncodedConverter, without the key.
+// It does not verify the token, so the returned segment must not be trusted.
+// It is useful for the frontend to read the display name or the roles.
+//
+// It can return InvalidTokenFormat or the error of base64 decoding.
+func PeekPublic(token string) ([]byte, error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return PeekPublicRaw(decodedToken)
+}
@@ -0,0 +1,90 @@
+package fst
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodedConverter_Segments(t *testing.T) {
+	converter := NewEncodedConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		Postfix:        []byte(`postfix`),
+		ExpirationTime: time.Minute * 5,
+	})
+
+	token, err := converter.NewSegmentedToken([]byte(`name=Alice`), []byte(`id=42`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := PeekPublic(token)
+	if err != nil {
+		t.Fatal("peek err: ", err)
+	}
+	if string(public) != `name=Alice` {
+		t.Fatal("unexpected public segment: ", string(public))
+	}
+
+	decodedToken, _ := base64.URLEncoding.DecodeString(token)
+	if bytes.Contains(decodedToken, []byte(`id=42`)) {
+		t.Fatal("private segment is not encrypted")
+	}
+
+	public, private, err := converter.ParseSegmentedToken(token)
+	if err != nil {
+		t.Fatal("token parse err: ", err)
+	}
+	if string(public) != `name=Alice` || string(private) != `id=42` {
+		t.Fatal("unexpected segments: ", string(public), " ", string(private))
+	}
+
+	// Both segments are covered by the tag.
+	for _, i := range []int{bytes.Index(decodedToken, []byte(`Alice`)), len(decodedToken) - 20} {
+		forged := append([]byte{}, decodedToken...)
+		forged[i] ^= 1
+
+		_, _, err = converter.ParseSegmentedToken(base64.URLEncoding.EncodeToString(forged))
+		if !errors.Is(err, InvalidSignature) {
+			t.Fatal("forged token parse err: ", err)
+		}
+	}
+
+	otherConverter := NewEncodedConverter(&ConverterConfig{
+		SecretKey:      []byte(`other`),
+		Postfix:        []byte(`postfix`),
+		ExpirationTime: time.Minute * 5,
+	})
+	if _, _, err = otherConverter.ParseSegmentedToken(token); !errors.Is(err, InvalidSignature) {
+		t.Fatal("token with the other key parse err: ", err)
+	}
+
+	if _, err = PeekPublic(converter.NewToken([]byte(`token`))); !errors.Is(err, InvalidTokenFormat) {
+		t.Fatal("not segmented token peek err: ", err)
+	}
+}
+
+func TestConverter_SegmentsExpired(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Millisecond * 100,
+		TimeResolution: time.Millisecond,
+	})
+
+	token, err := converter.NewSegmentedToken(nil, []byte(`private`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = converter.ParseSegmentedToken(token); err != nil {
+		t.Fatal("token parse err: ", err)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	if _, _, err = converter.ParseSegmentedToken(token); !errors.Is(err, TokenExpired) {
+		t.Fatal("token is not expired: ", err)
+	}
+}
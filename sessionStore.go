@@ -0,0 +1,130 @@
+package fst
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// SessionNotFound means that the session is not in the SessionStore: it is revoked, expired or has never existed.
+var SessionNotFound = errors.New("fst: session not found")
+
+// SessionStore stores the payloads of the reference tokens by their IDs, see ReferenceConverter.
+//
+// All methods must be safe for concurrent use.
+type SessionStore interface {
+	// Store saves the payload of the session with the id. The session can be deleted after the expiresAt.
+	// The zero expiresAt means that the session never expires.
+	Store(id, payload []byte, expiresAt time.Time) error
+	// Load returns the payload of the session with the id or SessionNotFound.
+	// The returned payload must not be modified.
+	Load(id []byte) ([]byte, error)
+	// Delete deletes the session with the id. It does nothing if the session does not exist.
+	Delete(id []byte) error
+}
+
+// sweepInterval is the minimum interval between the removals of the expired sessions from the MemorySessionStore.
+const sweepInterval = time.Minute
+
+// MemorySessionStore is a SessionStore that keeps the sessions in memory.
+// The expired sessions are removed lazily, when the store is used.
+//
+// Use NewMemorySessionStore to create it.
+type MemorySessionStore struct {
+	now       func() time.Time
+	mu        sync.RWMutex
+	sessions  map[string]storedSession
+	lastSweep time.Time
+}
+
+// MemorySessionStoreConfig represents the configuration options for creating a new MemorySessionStore.
+//
+// Now is the clock of the expiration of the sessions. It is time.Now by default.
+type MemorySessionStoreConfig struct {
+	// Now returns the current time used to check the expiration of the sessions. The expiration time of the sessions
+	// is set by the clock of the ReferenceConverter's Converter, so use the same ConverterConfig.Now here.
+	// It is time.Now by default.
+	Now func() time.Time
+}
+
+type storedSession struct {
+	payload   []byte
+	expiresAt time.Time
+}
+
+func (s storedSession) isExpired(now time.Time) bool {
+	return !s.expiresAt.IsZero() && s.expiresAt.Before(now)
+}
+
+// NewMemorySessionStore creates a new empty MemorySessionStore based on the provided fst.MemorySessionStoreConfig.
+func NewMemorySessionStore(cfg *MemorySessionStoreConfig) *MemorySessionStore {
+	s := &MemorySessionStore{
+		now:      cfg.Now,
+		sessions: make(map[string]storedSession),
+	}
+
+	if s.now == nil {
+		s.now = time.Now
+	}
+	s.lastSweep = s.now()
+
+	return s
+}
+
+// Store saves the copy of the payload of the session with the id.
+func (s *MemorySessionStore) Store(id, payload []byte, expiresAt time.Time) error {
+	now := s.now()
+
+	s.mu.Lock()
+	s.sessions[string(id)] = storedSession{
+		payload:   append([]byte(nil), payload...),
+		expiresAt: expiresAt,
+	}
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// sweep removes the expired sessions. It must be called with the locked mu.
+func (s *MemorySessionStore) sweep(now time.Time) {
+	for id, session := range s.sessions {
+		if session.isExpired(now) {
+			delete(s.sessions, id)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Load returns the payload of the session with the id or SessionNotFound.
+func (s *MemorySessionStore) Load(id []byte) ([]byte, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[string(id)]
+	s.mu.RUnlock()
+
+	if !ok || session.isExpired(s.now()) {
+		return nil, SessionNotFound
+	}
+
+	return session.payload, nil
+}
+
+// Delete deletes the session with the id.
+func (s *MemorySessionStore) Delete(id []byte) error {
+	s.mu.Lock()
+	delete(s.sessions, string(id))
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of the stored sessions, including the expired ones that are not removed yet.
+func (s *MemorySessionStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.sessions)
+}
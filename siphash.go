@@ -0,0 +1,135 @@
+package fst
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// siphash is SipHash-2-4 with the 64 or 128 bits output. It implements hash.Hash, Reset returns it to the keyed state.
+// SipHash is a short MAC for short messages, so it is suitable only for internal tokens.
+
+const sipHashBlockSize = 8
+
+type siphash struct {
+	k0, k1         uint64
+	v0, v1, v2, v3 uint64
+	buf            [sipHashBlockSize]byte
+	n              int
+	length         uint64
+	size           int
+}
+
+// newSipHash returns SipHash-2-4 with the size (8 or 16) bytes output. The key must be 16 bytes long.
+func newSipHash(size int, key []byte) hash.Hash {
+	d := &siphash{
+		k0:   binary.LittleEndian.Uint64(key[0:8]),
+		k1:   binary.LittleEndian.Uint64(key[8:16]),
+		size: size,
+	}
+	d.Reset()
+
+	return d
+}
+
+// zero zeroes the key and the state derived from it.
+func (d *siphash) zero() {
+	*d = siphash{size: d.size}
+}
+
+func (d *siphash) Size() int { return d.size }
+
+func (d *siphash) BlockSize() int { return sipHashBlockSize }
+
+func (d *siphash) Reset() {
+	d.v0 = d.k0 ^ 0x736f6d6570736575
+	d.v1 = d.k1 ^ 0x646f72616e646f6d
+	d.v2 = d.k0 ^ 0x6c7967656e657261
+	d.v3 = d.k1 ^ 0x7465646279746573
+	if d.size == 16 {
+		d.v1 ^= 0xee
+	}
+	d.n = 0
+	d.length = 0
+}
+
+func (d *siphash) Write(p []byte) (int, error) {
+	written := len(p)
+	d.length += uint64(len(p))
+
+	if d.n > 0 {
+		n := copy(d.buf[d.n:], p)
+		d.n += n
+		p = p[n:]
+
+		if d.n < sipHashBlockSize {
+			return written, nil
+		}
+
+		d.block(binary.LittleEndian.Uint64(d.buf[:]))
+		d.n = 0
+	}
+
+	for len(p) >= sipHashBlockSize {
+		d.block(binary.LittleEndian.Uint64(p))
+		p = p[sipHashBlockSize:]
+	}
+
+	d.n = copy(d.buf[:], p)
+
+	return written, nil
+}
+
+func (d *siphash) Sum(b []byte) []byte {
+	final := *d
+
+	var last [sipHashBlockSize]byte
+	copy(last[:], final.buf[:final.n])
+	last[7] = byte(final.length)
+	final.block(binary.LittleEndian.Uint64(last[:]))
+
+	if final.size == 16 {
+		final.v2 ^= 0xee
+	} else {
+		final.v2 ^= 0xff
+	}
+	final.rounds(4)
+	b = binary.LittleEndian.AppendUint64(b, final.v0^final.v1^final.v2^final.v3)
+
+	if final.size == 16 {
+		final.v1 ^= 0xdd
+		final.rounds(4)
+		b = binary.LittleEndian.AppendUint64(b, final.v0^final.v1^final.v2^final.v3)
+	}
+
+	return b
+}
+
+func (d *siphash) block(m uint64) {
+	d.v3 ^= m
+	d.rounds(2)
+	d.v0 ^= m
+}
+
+func (d *siphash) rounds(n int) {
+	v0, v1, v2, v3 := d.v0, d.v1, d.v2, d.v3
+
+	for i := 0; i < n; i++ {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	d.v0, d.v1, d.v2, d.v3 = v0, v1, v2, v3
+}
@@ -0,0 +1,156 @@
+package fst
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// SessionLifetimeExceeded means that the session is older than the MaxLifetime, so it can not be renewed anymore.
+var SessionLifetimeExceeded = errors.New("fst: session lifetime exceeded")
+
+// The value of the sliding session token is [uvarint session start milliseconds since 2024-01-01 UTC] [value].
+// The start is kept when the token is renewed, so the MaxLifetime is counted from the login, not from the last renewal.
+
+// SlidingSession represents sliding sessions: the session token expires after the ExpirationTime of the Converter
+// like other tokens, but ParseToken renews it when it is older than the RenewAfter, so the active users are not logged out.
+// The session can not be renewed after the MaxLifetime since its start.
+//
+// The value of the session token starts with the session start, so the Converter must be used only by the SlidingSession:
+// a token of the same Converter made by another code would be accepted as a session that started at its first bytes.
+//
+// # Example:
+//
+//	sessions := fst.NewSlidingSession(&fst.SlidingSessionConfig{
+//		Converter: fst.NewEncodedConverter(&fst.ConverterConfig{
+//			SecretKey:      []byte(`secret`),
+//			ExpirationTime: time.Hour,
+//		}),
+//		RenewAfter:  time.Minute * 30,
+//		MaxLifetime: time.Hour * 24 * 7,
+//	})
+//
+//	token := sessions.NewToken([]byte(`user 1`))
+//
+//	value, renewed, err := sessions.ParseToken(token)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	if renewed != "" {
+//		// Set the cookie to the renewed token.
+//	}
+//	fmt.Println(string(value)) // user 1
+type SlidingSession struct {
+	converter   *Converter
+	renewAfter  time.Duration
+	maxLifetime time.Duration
+}
+
+// SlidingSessionConfig represents the configuration options for creating a new SlidingSession.
+//
+// Converter is the EncodedConverter of the session tokens. It must use ExpirationTime and its own secret or Postfix.
+//
+// RenewAfter is the age of the token after which it is renewed. It is half of the ExpirationTime by default.
+//
+// MaxLifetime is the maximum lifetime of the session since its start. It is zero by default and the session can be renewed forever.
+type SlidingSessionConfig struct {
+	// Converter is the EncodedConverter of the session tokens. It must use ExpirationTime,
+	// that is the maximum time of inactivity.
+	// Use a Converter with its own secret or Postfix, so other tokens can not be parsed as the session tokens.
+	Converter *EncodedConverter
+	// RenewAfter is the age of the token after which ParseToken renews it. It is half of the ExpirationTime by default.
+	RenewAfter time.Duration
+	// MaxLifetime is the maximum lifetime of the session since its start, regardless of the renewals.
+	// It is zero by default and the session can be renewed forever.
+	MaxLifetime time.Duration
+}
+
+// NewSlidingSession creates a new instance of the SlidingSession based on the provided fst.SlidingSessionConfig.
+//
+// An example of usage can be found at SlidingSession.
+func NewSlidingSession(cfg *SlidingSessionConfig) *SlidingSession {
+	if cfg.Converter == nil || !cfg.Converter.converter.expires {
+		panic("fst: SlidingSessionConfig.Converter must use ExpirationTime")
+	}
+
+	s := &SlidingSession{
+		converter:   cfg.Converter.converter,
+		renewAfter:  cfg.RenewAfter,
+		maxLifetime: cfg.MaxLifetime,
+	}
+
+	if s.renewAfter == 0 {
+		s.renewAfter = s.converter.expirationTime / 2
+	}
+
+	return s
+}
+
+// NewToken creates a new session token with the provided value. The session starts now.
+// This method encodes the token in base64.
+//
+// If the Converter uses a RemoteSigner, NewToken returns an empty string when the signer fails.
+// Use NewTokenContext to get the error.
+func (s *SlidingSession) NewToken(value []byte) string {
+	token, _ := s.NewTokenContext(context.Background(), value)
+	return token
+}
+
+// NewTokenContext creates a new session token like NewToken, but passes the ctx to the RemoteSigner and returns its error.
+func (s *SlidingSession) NewTokenContext(ctx context.Context, value []byte) (string, error) {
+	sessionValue := appendTimestamp(make([]byte, 0, 6+len(value)), timeEncodingMilliseconds, s.converter.clock())
+	sessionValue = append(sessionValue, value...)
+
+	return s.newToken(ctx, sessionValue)
+}
+
+func (s *SlidingSession) newToken(ctx context.Context, sessionValue []byte) (string, error) {
+	token, err := s.converter.NewTokenContext(ctx, sessionValue)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(token), nil
+}
+
+// ParseToken parses the session token and returns its value. If the token is older than the RenewAfter,
+// it also returns the renewed token with the same value and session start, that must replace the old token,
+// like in the cookie. Otherwise, renewed is empty. This method will copy the token's value.
+//
+// It can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, SessionLifetimeExceeded
+// or the error of base64 decoding.
+func (s *SlidingSession) ParseToken(token string) (value []byte, renewed string, err error) {
+	return s.ParseTokenContext(context.Background(), token)
+}
+
+// ParseTokenContext parses the session token like ParseToken, but passes the ctx to the RemoteSigner.
+func (s *SlidingSession) ParseTokenContext(ctx context.Context, token string) (value []byte, renewed string, err error) {
+	decodedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.converter.clock()
+	f, err := s.converter.parseVerifiedFrame(ctx, decodedToken, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	startedAt, n := readTimestamp(f.payload, timeEncodingMilliseconds)
+	if n <= 0 {
+		return nil, "", InvalidTokenFormat
+	}
+
+	if s.maxLifetime > 0 && now.Sub(startedAt) > s.maxLifetime {
+		return nil, "", SessionLifetimeExceeded
+	}
+
+	if now.Sub(f.issuedAt) >= s.renewAfter {
+		if renewed, err = s.newToken(ctx, f.payload); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return f.payload[n:], renewed, nil
+}
@@ -0,0 +1,56 @@
+package fst
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlidingSession(t *testing.T) {
+	now := time.Now()
+	sessions := NewSlidingSession(&SlidingSessionConfig{
+		Converter: NewEncodedConverter(&ConverterConfig{
+			SecretKey:      []byte(`secret`),
+			ExpirationTime: time.Hour,
+			Now: func() time.Time {
+				return now
+			},
+		}),
+		RenewAfter:  time.Minute * 15,
+		MaxLifetime: time.Minute * 90,
+	})
+
+	token := sessions.NewToken([]byte(`user`))
+	first := token
+
+	value, renewed, err := sessions.ParseToken(token)
+	if err != nil || string(value) != "user" {
+		t.Fatal("Token parse err: ", err)
+	}
+	if renewed != "" {
+		t.Fatal("New token was renewed")
+	}
+
+	// The token is renewed twice, so the session outlives the ExpirationTime of the first token.
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Minute * 40)
+
+		value, renewed, err = sessions.ParseToken(token)
+		if err != nil || string(value) != "user" {
+			t.Fatal("Token parse err: ", err)
+		}
+		if renewed == "" {
+			t.Fatal("Old token was not renewed")
+		}
+		token = renewed
+	}
+
+	if _, _, err = sessions.ParseToken(first); !errors.Is(err, TokenExpired) {
+		t.Fatal("Expired token parse err: ", err)
+	}
+
+	now = now.Add(time.Minute * 40)
+	if _, _, err = sessions.ParseToken(token); !errors.Is(err, SessionLifetimeExceeded) {
+		t.Fatal("Too old session parse err: ", err)
+	}
+}
@@ -0,0 +1,349 @@
+package fst
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Stream layout:
+// [1 byte header?] [timestamp?] [16 bytes nonce] [chunk]...
+//
+// Chunk layout:
+// [uvarint dataLen<<1 | last] [data] [signature]
+//
+// The header and the timestamp are the same as in the token. The signature of the chunk number i is the MAC of
+// data || header || timestamp || nonce || uvarint(i) || last || postfix with the stream key derived from the secret.
+// The number protects the chunks from reordering, the last flag protects the stream from truncation
+// and the random nonce protects from mixing the chunks of different streams.
+
+const (
+	// StreamChunkSize is the maximum size of the data in one chunk of the stream.
+	// The reader of the stream never buffers more than one chunk.
+	StreamChunkSize = 64 << 10
+
+	streamNonceSize = 16
+)
+
+// StreamsNotSupported means that the Converter can not sign or verify streams, because it uses a RemoteSigner.
+var StreamsNotSupported = errors.New("fst: streams are not supported with a RemoteSigner")
+
+// SignStream reads the r until io.EOF and writes it to the w as a signed stream, that can be read by NewStreamReader.
+// Unlike NewToken, it does not keep the whole payload in memory: the payload is split into chunks
+// of StreamChunkSize bytes and each chunk is signed separately. This method does not encode the stream in base64.
+//
+// It returns the first error of the r or the w, StreamsNotSupported if the Converter uses a RemoteSigner
+// SymmetricKeyRequired if it uses AlgorithmEd25519 or KeyClosed if its key is closed.
+func (c *Converter) SignStream(w io.Writer, r io.Reader) error {
+	if c.signer != nil {
+		return StreamsNotSupported
+	}
+
+	key, mac, err := c.derivedSigningMac(purposeStream)
+	if err != nil {
+		return err
+	}
+
+	signatureSize := c.sizeOfSignature(mac)
+
+	meta := c.newMeta(c.now())
+	nonceOffset := len(meta)
+	meta = append(meta, make([]byte, streamNonceSize)...)
+	if _, err := rand.Read(meta[nonceOffset:]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(meta); err != nil {
+		return err
+	}
+
+	data := make([]byte, StreamChunkSize)
+	chunk := make([]byte, 0, binary.MaxVarintLen64+StreamChunkSize+mac.Size())
+	chunkMeta := make([]byte, 0, len(meta)+binary.MaxVarintLen64+1)
+
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(r, data)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+
+		chunkMeta = appendChunkMeta(chunkMeta[:0], meta, index, last)
+
+		chunk = binary.AppendUvarint(chunk[:0], uint64(n)<<1|boolToUint64(last))
+		chunk = append(chunk, data[:n]...)
+		chunk = mac.sum(chunk, data[:n], chunkMeta, c.postfix)
+		chunk = chunk[:len(chunk)-mac.Size()+signatureSize]
+		if key.isClosed() {
+			return KeyClosed
+		}
+
+		if _, err = w.Write(chunk); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// appendChunkMeta appends the meta of the chunk number index: the meta of the stream, the number and the last flag.
+func appendChunkMeta(dst, meta []byte, index uint64, last bool) []byte {
+	dst = append(dst, meta...)
+	dst = binary.AppendUvarint(dst, index)
+
+	return append(dst, byte(boolToUint64(last)))
+}
+
+func boolToUint64(b bool) uint64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// StreamReader is an io.Reader that reads the stream signed by SignStream and returns its payload.
+// It returns the data of the chunk only after the signature of the chunk is verified,
+// and it returns io.EOF only after the last chunk is verified, so a truncated stream is never read as a whole.
+//
+// Read can return errors like InvalidTokenFormat, InvalidSignature, TokenExpired, UnsupportedAlgorithm,
+// KeyClosed if the chunk is not verified and the key is closed, io.ErrUnexpectedEOF if the stream is truncated, or the error of the underlying reader.
+// The errors are sticky: after an error, Read returns it forever.
+//
+// Use Converter.NewStreamReader to create it.
+type StreamReader struct {
+	converter *Converter
+	r         *bufio.Reader
+
+	// meta is the part of the stream before the first chunk. It is nil until the first Read.
+	meta []byte
+	keys []*Key
+	// mac is the mac of the key that verified the first chunk. It is nil until the first chunk is verified.
+	mac       mac
+	key       *Key
+	algorithm Algorithm
+
+	index     uint64
+	last      bool
+	chunk     []byte
+	chunkMeta []byte
+	// pending is the verified data that is not read yet.
+	pending []byte
+	err     error
+}
+
+// NewStreamReader returns a StreamReader that reads and verifies the stream signed by SignStream from the r.
+// It reads nothing before the first Read.
+func (c *Converter) NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{
+		converter: c,
+		r:         bufio.NewReader(r),
+	}
+}
+
+// Read reads the verified payload of the stream into p.
+func (s *StreamReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		s.err = s.next()
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+
+	return n, nil
+}
+
+// next reads and verifies the next chunk. It returns io.EOF after the last chunk.
+func (s *StreamReader) next() error {
+	if s.last {
+		return io.EOF
+	}
+
+	c := s.converter
+	if s.meta == nil {
+		if c.signer != nil {
+			return StreamsNotSupported
+		}
+
+		if err := s.readMeta(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	s.chunk, err = readUvarintBytes(s.r, s.chunk[:0])
+	if err != nil {
+		return err
+	}
+
+	length, n := getUvarint(s.chunk)
+	if n <= 0 || length>>1 > StreamChunkSize {
+		return InvalidTokenFormat
+	}
+	dataLen, last := int(length>>1), length&1 == 1
+
+	mac, closed := s.mac, false
+	for i := 0; mac == nil && i < len(s.keys); i++ {
+		mac = s.keys[i].derivedMac(purposeStream, s.algorithm)
+		closed = closed || s.keys[i].isClosed()
+	}
+	if mac == nil {
+		if closed {
+			return KeyClosed
+		}
+
+		return SymmetricKeyRequired
+	}
+	signatureSize := c.sizeOfSignature(mac)
+
+	if cap(s.chunk) < dataLen+signatureSize {
+		s.chunk = make([]byte, 0, StreamChunkSize+mac.Size())
+	}
+	s.chunk = s.chunk[:dataLen+signatureSize]
+	if _, err = io.ReadFull(s.r, s.chunk); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	data, signature := s.chunk[:dataLen], s.chunk[dataLen:]
+	s.chunkMeta = appendChunkMeta(s.chunkMeta[:0], s.meta, s.index, last)
+
+	if s.mac == nil {
+		for _, key := range s.keys {
+			mac = key.derivedMac(purposeStream, s.algorithm)
+			if mac != nil && c.sizeOfSignature(mac) == signatureSize && mac.verify(signature, data, s.chunkMeta, c.postfix) {
+				s.mac, s.key = mac, key
+				break
+			}
+			closed = closed || key.isClosed()
+		}
+
+		if s.mac == nil {
+			return unverifiedError(closed)
+		}
+	} else if !mac.verify(signature, data, s.chunkMeta, c.postfix) {
+		return unverifiedError(s.key.isClosed())
+	}
+
+	s.index++
+	s.last = last
+	s.pending = data
+
+	return nil
+}
+
+// readMeta reads the header, the timestamp and the nonce of the stream and checks the algorithm and the expiration time.
+func (s *StreamReader) readMeta() error {
+	c := s.converter
+	meta := make([]byte, 0, 1+binary.MaxVarintLen64+streamNonceSize)
+	timeEncoding := byte(timeEncodingUnixSeconds)
+
+	if c.withHeader {
+		header, err := s.r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		meta = append(meta, header)
+
+		if header&headerVarintFlag == 0 {
+			return InvalidTokenFormat
+		}
+
+		timeEncoding = (header & headerTimeMask) >> headerTimeShift
+		if timeEncoding >= timeEncodingCount || (!c.expires && timeEncoding != timeEncodingUnixSeconds) {
+			return InvalidTokenFormat
+		}
+
+		s.algorithm = Algorithm(header & headerAlgorithmMask)
+		if int(s.algorithm) >= algorithmCount || !c.accepted[s.algorithm] {
+			return UnsupportedAlgorithm
+		}
+	}
+
+	if c.expires {
+		timestampOffset := len(meta)
+
+		var err error
+		if timeEncoding == timeEncodingUnixSeconds {
+			meta = meta[:len(meta)+8]
+			_, err = io.ReadFull(s.r, meta[len(meta)-8:])
+		} else {
+			meta, err = readUvarintBytes(s.r, meta)
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		issuedAt, n := readTimestamp(meta[timestampOffset:], timeEncoding)
+		if n <= 0 {
+			return InvalidTokenFormat
+		}
+
+		if c.isExpired(issuedAt, timeEncoding, c.clock()) {
+			return TokenExpired
+		}
+	}
+
+	meta = meta[:len(meta)+streamNonceSize]
+	if _, err := io.ReadFull(s.r, meta[len(meta)-streamNonceSize:]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	s.meta = meta
+	s.keys = []*Key{c.key}
+	if c.keySource != nil {
+		s.keys = c.keySource.VerificationKeys()
+	}
+
+	return nil
+}
+
+// readUvarintBytes reads the bytes of one uvarint from the r and appends them to the dst.
+// It does not check that the uvarint is minimal, use getUvarint for it.
+func readUvarintBytes(r io.ByteReader, dst []byte) ([]byte, error) {
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return dst, unexpectedEOF(err)
+		}
+
+		dst = append(dst, b)
+		if b < 0x80 {
+			return dst, nil
+		}
+	}
+
+	return dst, InvalidTokenFormat
+}
+
+// unexpectedEOF replaces io.EOF with io.ErrUnexpectedEOF, because the stream can end only after the last chunk.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// SignStream signs the r like Converter.SignStream and writes the stream encoded in base64 to the w.
+func (c *EncodedConverter) SignStream(w io.Writer, r io.Reader) error {
+	encoder := base64.NewEncoder(base64.URLEncoding, w)
+	if err := c.converter.SignStream(encoder, r); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// NewStreamReader returns a StreamReader that reads and verifies the stream signed by EncodedConverter.SignStream.
+func (c *EncodedConverter) NewStreamReader(r io.Reader) *StreamReader {
+	return c.converter.NewStreamReader(base64.NewDecoder(base64.URLEncoding, r))
+}
@@ -0,0 +1,112 @@
+package fst
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConverter_Stream(t *testing.T) {
+	payload := make([]byte, StreamChunkSize*2+100)
+	rand.Read(payload)
+
+	for _, cfg := range []*ConverterConfig{
+		{SecretKey: []byte(`secret`)},
+		{SecretKey: []byte(`secret`), Postfix: []byte(`postfix`), ExpirationTime: time.Minute * 5},
+		{SecretKey: []byte(`secret`), ExpirationTime: time.Minute, TimeResolution: time.Millisecond, Algorithm: AlgorithmBLAKE2b256},
+	} {
+		converter := NewConverter(cfg)
+
+		for _, size := range []int{0, 5, StreamChunkSize, len(payload)} {
+			var stream bytes.Buffer
+			if err := converter.SignStream(&stream, bytes.NewReader(payload[:size])); err != nil {
+				t.Fatal("SignStream err: ", err)
+			}
+
+			actual, err := io.ReadAll(converter.NewStreamReader(&stream))
+			if err != nil {
+				t.Fatal("Stream read err: ", err)
+			}
+			if !bytes.Equal(actual, payload[:size]) {
+				t.Fatal("Stream of ", size, " bytes was read as ", len(actual), " bytes")
+			}
+		}
+	}
+}
+
+func TestConverter_StreamTampered(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+
+	payload := make([]byte, StreamChunkSize+100)
+	var stream bytes.Buffer
+	if err := converter.SignStream(&stream, bytes.NewReader(payload)); err != nil {
+		t.Fatal("SignStream err: ", err)
+	}
+	signed := stream.Bytes()
+
+	// The first chunk is returned only after it is verified, so the flipped byte is never read.
+	tampered := bytes.Clone(signed)
+	tampered[len(tampered)-StreamChunkSize] ^= 1
+	reader := converter.NewStreamReader(bytes.NewReader(tampered))
+	if n, err := reader.Read(make([]byte, 10)); n != 0 || !errors.Is(err, InvalidSignature) {
+		t.Fatal("Tampered stream read ", n, " bytes with err: ", err)
+	}
+
+	// The stream without the last chunk must not be read as a whole.
+	firstChunkEnd := streamNonceSize + 3 + StreamChunkSize + 32
+	_, err := io.ReadAll(converter.NewStreamReader(bytes.NewReader(signed[:firstChunkEnd])))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("Truncated stream read err: ", err)
+	}
+
+	// The stream must not be read by a token parser and vice versa.
+	if _, err = converter.ParseToken(signed); err == nil {
+		t.Fatal("Stream was parsed as a token")
+	}
+}
+
+func TestEncodedConverter_Stream(t *testing.T) {
+	converter := NewEncodedConverter(&ConverterConfig{
+		SecretKey:      []byte(`secret`),
+		ExpirationTime: time.Minute * 5,
+	})
+
+	var stream strings.Builder
+	if err := converter.SignStream(&stream, strings.NewReader("large value")); err != nil {
+		t.Fatal("SignStream err: ", err)
+	}
+
+	actual, err := io.ReadAll(converter.NewStreamReader(strings.NewReader(stream.String())))
+	if err != nil {
+		t.Fatal("Stream read err: ", err)
+	}
+	if string(actual) != "large value" {
+		t.Fatal("Stream was read as ", string(actual))
+	}
+}
+
+func TestConverter_StreamClosed(t *testing.T) {
+	converter := NewConverter(&ConverterConfig{
+		SecretKey: []byte(`secret`),
+	})
+
+	var stream bytes.Buffer
+	if err := converter.SignStream(&stream, strings.NewReader("value")); err != nil {
+		t.Fatal("SignStream err: ", err)
+	}
+
+	converter.Close()
+
+	if err := converter.SignStream(io.Discard, strings.NewReader("value")); !errors.Is(err, KeyClosed) {
+		t.Fatal("SignStream of a closed key err: ", err)
+	}
+	if _, err := io.ReadAll(converter.NewStreamReader(&stream)); !errors.Is(err, KeyClosed) {
+		t.Fatal("Stream read with a closed key err: ", err)
+	}
+}
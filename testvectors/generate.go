@@ -0,0 +1,234 @@
+package testvectors
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+// The inputs of the cases. The issue time has nanoseconds, so the cases show how each time encoding truncates it.
+var (
+	issuedAt   = time.Date(2024, time.June, 1, 12, 0, 0, 123456789, time.UTC)
+	secret     = []byte("fst test vectors secret")
+	longSecret = bytes.Repeat([]byte("long secret "), 8)
+	seed       = []byte("fst test vectors ed25519 seed 32")
+	payload    = []byte("user 1")
+)
+
+// Generate creates the corpus. It is deterministic, so the corpus is the same on every run.
+func Generate() (*Corpus, error) {
+	g := &generator{corpus: &Corpus{Version: Version}}
+
+	binaryPayload := make([]byte, 256)
+	for i := range binaryPayload {
+		binaryPayload[i] = byte(i)
+	}
+
+	hmac := Config{Algorithm: "hmac-sha256", Secret: secret}
+	hmacExpiring := hmac
+	hmacExpiring.ExpirationTime = time.Hour
+	hmacPostfix := hmac
+	hmacPostfix.Postfix = []byte("fst postfix")
+	hmacTruncated := hmac
+	hmacTruncated.SignatureSize = fst.MinSignatureSize
+	hmacLongSecret := hmac
+	hmacLongSecret.Secret = longSecret
+	hmacHeader := hmac
+	hmacHeader.AcceptedAlgorithms = []string{"blake2b-256"}
+	hmacNanoseconds := hmac
+	hmacNanoseconds.ExpirationTime = time.Millisecond
+	hmacNanoseconds.TimeResolution = time.Nanosecond
+	anotherSecret := hmac
+	anotherSecret.Secret = []byte("another secret")
+
+	blake2b := Config{Algorithm: "blake2b-256", Secret: secret}
+	blake2bLongSecret := blake2b
+	blake2bLongSecret.Secret = longSecret
+	blake2bSeconds := blake2b
+	blake2bSeconds.ExpirationTime = time.Hour
+	blake2bSeconds.TimeResolution = time.Second
+
+	sipHash := Config{Algorithm: "siphash-2-4-128", Secret: secret}
+	sipHashMilliseconds := sipHash
+	sipHashMilliseconds.ExpirationTime = time.Minute
+	sipHashMilliseconds.TimeResolution = time.Millisecond
+
+	ed := Config{
+		Algorithm:      "ed25519",
+		Seed:           seed,
+		PublicKey:      Hex(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)),
+		ExpirationTime: time.Hour,
+		TimeResolution: time.Microsecond,
+	}
+
+	// The valid tokens.
+	g.valid("hmac-sha256/basic", "The default configuration: no header, no timestamp.", hmac, payload, issuedAt)
+	g.valid("hmac-sha256/binary-payload", "The payload is arbitrary bytes.", hmac, binaryPayload, issuedAt)
+	g.valid("hmac-sha256/postfix", "The postfix is signed, but is not in the token.", hmacPostfix, payload, issuedAt)
+	g.valid("hmac-sha256/truncated-signature", "The signature is truncated to 16 bytes.", hmacTruncated, payload, issuedAt)
+	g.valid("hmac-sha256/long-secret", "The secret is longer than the block of SHA-256.", hmacLongSecret, payload, issuedAt)
+	g.valid("hmac-sha256/header", "The accepted algorithms add the header with the algorithm ID 0.", hmacHeader, payload, issuedAt)
+	g.valid("hmac-sha256/expiration", "The 8 bytes Unix seconds timestamp.", hmacExpiring, payload, issuedAt.Add(time.Minute*30))
+	g.valid("hmac-sha256/expiration-boundary", "The Unix seconds token is valid until the second of the expiration ends.",
+		hmacExpiring, payload, issuedAt.Add(time.Hour))
+	g.valid("hmac-sha256/nanoseconds", "The compact timestamp in nanoseconds is valid at its expiration time.",
+		hmacNanoseconds, payload, issuedAt.Add(time.Millisecond))
+	g.valid("blake2b-256/basic", "The keyed BLAKE2b-256 with the header.", blake2b, payload, issuedAt)
+	g.valid("blake2b-256/long-secret", "The secret longer than 64 bytes is hashed with BLAKE2b-512.", blake2bLongSecret, payload, issuedAt)
+	g.valid("blake2b-256/seconds", "The compact timestamp in seconds.", blake2bSeconds, payload, issuedAt.Add(time.Minute*30))
+	g.valid("siphash-2-4-128/milliseconds", "SipHash with the derived key and the compact timestamp in milliseconds.",
+		sipHashMilliseconds, payload, issuedAt.Add(time.Second*30))
+	g.valid("ed25519/microseconds", "Ed25519 with the compact timestamp in microseconds.", ed, payload, issuedAt.Add(time.Minute*30))
+
+	// The expired tokens.
+	g.signed("hmac-sha256/expired", "The Unix seconds token is expired one second after its expiration.",
+		hmacExpiring, hmacExpiring, issuedAt.Add(time.Hour+time.Second), fst.ReasonExpired, nil)
+	g.signed("hmac-sha256/nanoseconds-expired", "The compact timestamp in nanoseconds is expired after its expiration time.",
+		hmacNanoseconds, hmacNanoseconds, issuedAt.Add(time.Millisecond+time.Nanosecond), fst.ReasonExpired, nil)
+	g.signed("blake2b-256/truncated-timestamp-expired", "The timestamp in seconds is truncated, so the token expires earlier.",
+		blake2bSeconds, blake2bSeconds, issuedAt.Add(time.Hour), fst.ReasonExpired, nil)
+
+	// The forged tokens.
+	g.signed("hmac-sha256/flipped-signature", "A bit of the signature is flipped.",
+		hmac, hmac, issuedAt, fst.ReasonInvalidSignature, flip(1))
+	g.signed("hmac-sha256/flipped-payload", "A bit of the payload is flipped.",
+		hmac, hmac, issuedAt, fst.ReasonInvalidSignature, flip(-1))
+	g.signed("hmac-sha256/wrong-secret", "The token is signed with another secret.",
+		anotherSecret, hmac, issuedAt, fst.ReasonInvalidSignature, nil)
+	g.signed("hmac-sha256/wrong-postfix", "The token is signed with a postfix, but is parsed without it.",
+		hmacPostfix, hmac, issuedAt, fst.ReasonInvalidSignature, nil)
+	g.signed("hmac-sha256/unexpected-truncated-signature", "The truncated signature is parsed by the Converter without the SignatureSize.",
+		hmacTruncated, hmac, issuedAt, fst.ReasonInvalidSignature, nil)
+	g.signed("hmac-sha256/legacy-framing", "The varint framing bit of the header is cleared, so the signed header differs.",
+		hmacHeader, hmacHeader, issuedAt, fst.ReasonInvalidSignature, firstByte(func(b byte) byte { return b &^ 0x80 }))
+	g.signed("ed25519/flipped-signature", "A bit of the Ed25519 signature is flipped.",
+		ed, ed, issuedAt, fst.ReasonInvalidSignature, flip(-len(payload)-1))
+
+	// The tokens with the algorithms that are not accepted.
+	g.signed("hmac-sha256/unsupported-algorithm", "The token is signed with SipHash, that is not accepted.",
+		sipHash, hmacHeader, issuedAt, fst.ReasonUnsupportedAlgorithm, nil)
+	g.signed("hmac-sha256/unknown-algorithm", "The header has the unknown algorithm ID 9.",
+		hmacHeader, hmacHeader, issuedAt, fst.ReasonUnsupportedAlgorithm, firstByte(func(byte) byte { return 0x89 }))
+
+	// The malformed tokens.
+	g.malformed("hmac-sha256/empty", "The token is empty.", hmac, nil)
+	g.malformed("hmac-sha256/too-short", "The token is shorter than the length, the signature and the payload.", hmac, []byte{1, 0})
+	g.signed("hmac-sha256/no-payload", "The payload is empty.",
+		hmac, hmac, issuedAt, fst.ReasonInvalidFormat, keep(-len(payload)))
+	g.signed("hmac-sha256/signature-length-too-large", "The signature length is larger than the rest of the token.",
+		hmac, hmac, issuedAt, fst.ReasonInvalidFormat, firstByte(func(byte) byte { return 0xfe }))
+	g.malformed("hmac-sha256/short-long-length", "The 0xff length marker is not followed by the length bytes.",
+		hmac, []byte{0xff, 0x01, 0x02, 0x03, 0x04})
+	g.malformed("hmac-sha256/header-only", "The token has only the header.", hmacHeader, []byte{0x80})
+	g.malformed("hmac-sha256/non-minimal-length", "The signature length is a non-minimal uvarint.",
+		hmacHeader, append([]byte{0x80, 0xa0, 0x00}, bytes.Repeat([]byte{0x01}, 33)...))
+	g.signed("hmac-sha256/time-encoding-without-expiration", "The header has a time encoding, but the Converter does not expire tokens.",
+		hmacHeader, hmacHeader, issuedAt, fst.ReasonInvalidFormat, firstByte(func(b byte) byte { return b | 0x10 }))
+	g.signed("blake2b-256/invalid-time-encoding", "The header has the reserved time encoding 5.",
+		blake2bSeconds, blake2bSeconds, issuedAt, fst.ReasonInvalidFormat, firstByte(func(b byte) byte { return b&^0x70 | 0x50 }))
+	g.signed("blake2b-256/truncated-timestamp", "The token ends inside the compact timestamp.",
+		blake2bSeconds, blake2bSeconds, issuedAt, fst.ReasonInvalidFormat, keep(3))
+
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	return g.corpus, nil
+}
+
+type generator struct {
+	corpus *Corpus
+	err    error
+}
+
+// sign creates the token of the payload with the Config at the issuedAt.
+func (g *generator) sign(cfg Config, value []byte) []byte {
+	converter, err := cfg.NewConverter(issuedAt)
+	if err != nil {
+		g.err = err
+		return nil
+	}
+	defer converter.Close()
+
+	token, err := converter.NewTokenContext(context.Background(), value)
+	if err != nil {
+		g.err = err
+	}
+
+	return token
+}
+
+func (g *generator) add(name, description string, cfg Config, value, token []byte, verifyAt time.Time, reason fst.Reason) {
+	c := Case{
+		Name:        name,
+		Description: description,
+		Config:      cfg,
+		Payload:     value,
+		Timestamp:   issuedAt,
+		VerifyAt:    verifyAt,
+		Token:       base64.URLEncoding.EncodeToString(token),
+	}
+	if reason != fst.ReasonOK {
+		c.Error = reason.String()
+	}
+
+	g.corpus.Cases = append(g.corpus.Cases, c)
+}
+
+// valid adds the valid token of the value.
+func (g *generator) valid(name, description string, cfg Config, value []byte, verifyAt time.Time) {
+	g.add(name, description, cfg, value, g.sign(cfg, value), verifyAt, fst.ReasonOK)
+}
+
+// signed adds the token of the payload that is signed with the signer Config, changed by the mutate if it is not nil,
+// and is parsed with the verifier Config.
+func (g *generator) signed(name, description string, signer, verifier Config, verifyAt time.Time, reason fst.Reason,
+	mutate func(token []byte) []byte) {
+	token := g.sign(signer, payload)
+	if mutate != nil && len(token) > 0 {
+		token = mutate(token)
+	}
+
+	g.add(name, description, verifier, payload, token, verifyAt, reason)
+}
+
+// malformed adds the hand-made token without the payload that is parsed with the Config.
+func (g *generator) malformed(name, description string, cfg Config, token []byte) {
+	g.add(name, description, cfg, nil, token, issuedAt, fst.ReasonInvalidFormat)
+}
+
+// flip returns the mutation that flips the lowest bit of the byte at the i or, if it is negative, at the len(token)+i.
+func flip(i int) func(token []byte) []byte {
+	return func(token []byte) []byte {
+		if i < 0 {
+			token[len(token)+i] ^= 1
+		} else {
+			token[i] ^= 1
+		}
+
+		return token
+	}
+}
+
+// firstByte returns the mutation that updates the first byte: the header or, if there is no header, the signature length.
+func firstByte(update func(b byte) byte) func(token []byte) []byte {
+	return func(token []byte) []byte {
+		token[0] = update(token[0])
+		return token
+	}
+}
+
+// keep returns the mutation that keeps the first n bytes or, if it is negative, removes the last -n bytes.
+func keep(n int) func(token []byte) []byte {
+	return func(token []byte) []byte {
+		if n < 0 {
+			return token[:len(token)+n]
+		}
+
+		return token[:n]
+	}
+}
@@ -0,0 +1,283 @@
+// Package testvectors provides the corpus of the Fast Signed Token test vectors, that lets the implementations
+// in other languages check that they create and parse the same tokens as this library. The format is specified in SPEC.md.
+//
+// The corpus is the vectors.json file. Every Case has the Config of the Converter, the payload, the time of issue,
+// the time of verification, the token and the expected error of the parsing. The valid tokens are reproducible,
+// because all algorithms are deterministic, so an implementation must create the same token at the same time.
+//
+// The corpus is regenerated with:
+//
+//	go test ./testvectors -update
+package testvectors
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/Eugene-Usachev/fst"
+)
+
+// Version is the version of the corpus. It is changed only if the format of the tokens or of the corpus changes.
+const Version = 1
+
+var (
+	// UnknownAlgorithm means that the Config has an algorithm name that is not in Algorithms.
+	UnknownAlgorithm = errors.New("testvectors: unknown algorithm")
+	// UnsupportedVersion means that the corpus has another Version.
+	UnsupportedVersion = errors.New("testvectors: unsupported corpus version")
+)
+
+// Algorithms are the names of the algorithms in the Config.
+var Algorithms = map[string]fst.Algorithm{
+	"hmac-sha256":     fst.AlgorithmHMAC,
+	"blake2b-256":     fst.AlgorithmBLAKE2b256,
+	"siphash-2-4-128": fst.AlgorithmSipHash128,
+	"ed25519":         fst.AlgorithmEd25519,
+}
+
+//go:embed vectors.json
+var vectors []byte
+
+// Hex is the byte slice that is encoded in JSON as a lowercase hex string.
+type Hex []byte
+
+// MarshalText encodes the bytes in hex.
+func (h Hex) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+// UnmarshalText decodes the bytes from hex.
+func (h *Hex) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+
+	*h = decoded
+	return nil
+}
+
+// Corpus is the set of the test vectors.
+type Corpus struct {
+	// Version is the version of the corpus, see Version.
+	Version int `json:"version"`
+	// Cases are the test vectors.
+	Cases []Case `json:"cases"`
+}
+
+// Config is the configuration of the Converter that creates and parses the token of the Case.
+// The zero fields are omitted in JSON and mean the defaults of the fst.ConverterConfig.
+type Config struct {
+	// Algorithm is the name of the algorithm, see Algorithms.
+	Algorithm string `json:"algorithm"`
+	// AcceptedAlgorithms are the names of the algorithms that are accepted in addition to the Algorithm.
+	AcceptedAlgorithms []string `json:"accepted_algorithms,omitempty"`
+	// Secret is the secret key of the symmetric algorithms.
+	Secret Hex `json:"secret,omitempty"`
+	// Seed is the RFC 8032 private key of the Ed25519 algorithm.
+	Seed Hex `json:"seed,omitempty"`
+	// PublicKey is the Ed25519 public key of the Seed. It is enough to verify the token.
+	PublicKey Hex `json:"public_key,omitempty"`
+	// Postfix is the postfix that is signed with the token.
+	Postfix Hex `json:"postfix,omitempty"`
+	// ExpirationTime is the expiration time in nanoseconds.
+	ExpirationTime time.Duration `json:"expiration_time_ns,omitempty"`
+	// TimeResolution is the resolution of the timestamp in nanoseconds.
+	TimeResolution time.Duration `json:"time_resolution_ns,omitempty"`
+	// SignatureSize is the size of the truncated signature.
+	SignatureSize int `json:"signature_size,omitempty"`
+}
+
+// NewConverter creates the Converter of the Config with the clock that always returns the now.
+// The caller should close it.
+func (cfg *Config) NewConverter(now time.Time) (*fst.Converter, error) {
+	algorithm, ok := Algorithms[cfg.Algorithm]
+	if !ok {
+		return nil, UnknownAlgorithm
+	}
+
+	converterConfig := &fst.ConverterConfig{
+		SecretKey:      cfg.Secret,
+		Postfix:        cfg.Postfix,
+		ExpirationTime: cfg.ExpirationTime,
+		Algorithm:      algorithm,
+		SignatureSize:  cfg.SignatureSize,
+		TimeResolution: cfg.TimeResolution,
+		Now: func() time.Time {
+			return now
+		},
+	}
+
+	for _, name := range cfg.AcceptedAlgorithms {
+		accepted, ok := Algorithms[name]
+		if !ok {
+			return nil, UnknownAlgorithm
+		}
+		converterConfig.AcceptedAlgorithms = append(converterConfig.AcceptedAlgorithms, accepted)
+	}
+
+	if algorithm == fst.AlgorithmEd25519 {
+		converterConfig.KeySource = fst.NewStaticKeySource(fst.NewEd25519Key("", ed25519.NewKeyFromSeed(cfg.Seed)))
+	}
+
+	return fst.NewConverter(converterConfig), nil
+}
+
+// Case is a test vector.
+type Case struct {
+	// Name is the unique name of the case, like "hmac-sha256/expired".
+	Name string `json:"name"`
+	// Description explains what the case checks.
+	Description string `json:"description"`
+	// Config is the configuration of the Converter.
+	Config Config `json:"config"`
+	// Payload is the value of the token. For the invalid tokens it is the value that was signed, if any.
+	Payload Hex `json:"payload"`
+	// Timestamp is the time of issue of the token.
+	Timestamp time.Time `json:"timestamp"`
+	// VerifyAt is the time when the token is parsed.
+	VerifyAt time.Time `json:"verify_at"`
+	// Token is the token in the base64 URL encoding with padding, like the tokens of the EncodedConverter.
+	Token string `json:"token"`
+	// Error is the expected error of the parsing: one of the fst.Reason names, like "invalid_signature".
+	// It is empty if the token is valid.
+	Error string `json:"error,omitempty"`
+}
+
+// MismatchError means that the Converter does not behave as the Case expects.
+type MismatchError struct {
+	// Case is the name of the case.
+	Case string
+	// Field is what differs: "error", "payload" or "token".
+	Field string
+	// Got is the actual value.
+	Got string
+	// Want is the expected value.
+	Want string
+}
+
+// Error returns the description of the mismatch.
+func (e *MismatchError) Error() string {
+	return "testvectors: case " + e.Case + ": " + e.Field + " is " + e.Got + ", want " + e.Want
+}
+
+// Verify parses the token of the Case at the VerifyAt and checks the error and the payload.
+// If the token is valid, it also creates the token at the Timestamp and checks that it is the same.
+// It returns a MismatchError if the Converter does not behave as the Case expects.
+func (c *Case) Verify() error {
+	token, err := base64.URLEncoding.DecodeString(c.Token)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := c.Config.NewConverter(c.VerifyAt)
+	if err != nil {
+		return err
+	}
+	defer verifier.Close()
+
+	value, err := verifier.ParseToken(token)
+	if got := ErrorName(err); got != c.Error {
+		return &MismatchError{Case: c.Name, Field: "error", Got: quote(got), Want: quote(c.Error)}
+	}
+
+	if c.Error != "" {
+		return nil
+	}
+
+	if !bytes.Equal(value, c.Payload) {
+		return &MismatchError{Case: c.Name, Field: "payload", Got: hex.EncodeToString(value), Want: hex.EncodeToString(c.Payload)}
+	}
+
+	signer, err := c.Config.NewConverter(c.Timestamp)
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+
+	newToken, err := signer.NewTokenContext(context.Background(), c.Payload)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(newToken, token) {
+		return &MismatchError{Case: c.Name, Field: "token", Got: base64.URLEncoding.EncodeToString(newToken), Want: c.Token}
+	}
+
+	return nil
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
+
+// ErrorName returns the name of the parsing error used in Case.Error: the name of its fst.Reason
+// or the empty string if the err is nil.
+func ErrorName(err error) string {
+	var reason fst.Reason
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, fst.InvalidTokenFormat):
+		reason = fst.ReasonInvalidFormat
+	case errors.Is(err, fst.InvalidSignature):
+		reason = fst.ReasonInvalidSignature
+	case errors.Is(err, fst.TokenExpired):
+		reason = fst.ReasonExpired
+	case errors.Is(err, fst.UnsupportedAlgorithm):
+		reason = fst.ReasonUnsupportedAlgorithm
+	default:
+		reason = fst.ReasonOther
+	}
+
+	return reason.String()
+}
+
+// Verify verifies all cases of the corpus and returns the errors of the failed ones.
+func (c *Corpus) Verify() []error {
+	var errs []error
+	for i := range c.Cases {
+		if err := c.Cases[i].Verify(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Load returns the corpus from the vectors.json embedded in the package.
+func Load() (*Corpus, error) {
+	return Read(bytes.NewReader(vectors))
+}
+
+// Read reads the corpus in JSON from the r.
+//
+// It can return UnsupportedVersion.
+func Read(r io.Reader) (*Corpus, error) {
+	corpus := &Corpus{}
+	if err := json.NewDecoder(r).Decode(corpus); err != nil {
+		return nil, err
+	}
+
+	if corpus.Version != Version {
+		return nil, UnsupportedVersion
+	}
+
+	return corpus, nil
+}
+
+// Write writes the corpus in the indented JSON to the w.
+func (c *Corpus) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(c)
+}
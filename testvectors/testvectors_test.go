@@ -0,0 +1,78 @@
+package testvectors
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite vectors.json")
+
+func TestCorpus(t *testing.T) {
+	generated, err := Generate()
+	if err != nil {
+		t.Fatal("Generate err: ", err)
+	}
+
+	var buf bytes.Buffer
+	if err = generated.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err = os.WriteFile("vectors.json", buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.ReadFile("vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(file, buf.Bytes()) {
+		t.Fatal("vectors.json is outdated, run go test ./testvectors -update")
+	}
+
+	corpus, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal("Read err: ", err)
+	}
+
+	names := make(map[string]bool, len(corpus.Cases))
+	for _, c := range corpus.Cases {
+		if names[c.Name] {
+			t.Error("Case name is not unique: ", c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	for _, err = range corpus.Verify() {
+		t.Error(err)
+	}
+}
+
+func TestCase_VerifyMismatch(t *testing.T) {
+	corpus, err := Load()
+	if err != nil {
+		t.Fatal("Load err: ", err)
+	}
+
+	c := corpus.Cases[0]
+	c.VerifyAt = c.Timestamp.Add(time.Hour)
+	c.Config.ExpirationTime = time.Minute
+
+	var mismatch *MismatchError
+	if err = c.Verify(); !errors.As(err, &mismatch) || mismatch.Field != "error" {
+		t.Fatal("Verify of the changed case err: ", err)
+	}
+
+	c = corpus.Cases[0]
+	c.Payload = []byte(`another payload`)
+	if err = c.Verify(); !errors.As(err, &mismatch) || mismatch.Field != "payload" {
+		t.Fatal("Verify of the changed payload err: ", err)
+	}
+}
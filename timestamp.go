@@ -0,0 +1,83 @@
+package fst
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Time encodings, stored in the bits 4-6 of the token header.
+const (
+	// timeEncodingUnixSeconds is 8 bytes of Unix seconds. It is used by the tokens without the header.
+	timeEncodingUnixSeconds = iota
+	timeEncodingSeconds
+	timeEncodingMilliseconds
+	timeEncodingMicroseconds
+	timeEncodingNanoseconds
+
+	timeEncodingCount
+)
+
+// epochNano is the start of the compact timestamps: 2024-01-01 UTC. The compact timestamp is a uvarint
+// of the time units since the epoch, so tokens for the next 100 years need 5 bytes with seconds resolution.
+var epochNano = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+
+var timeEncodingUnits = [timeEncodingCount]int64{
+	timeEncodingUnixSeconds:  int64(time.Second),
+	timeEncodingSeconds:      int64(time.Second),
+	timeEncodingMilliseconds: int64(time.Millisecond),
+	timeEncodingMicroseconds: int64(time.Microsecond),
+	timeEncodingNanoseconds:  int64(time.Nanosecond),
+}
+
+// timeEncodingFor returns the time encoding of the resolution. It reports false if the resolution is not supported.
+func timeEncodingFor(resolution time.Duration) (byte, bool) {
+	switch resolution {
+	case 0:
+		return timeEncodingUnixSeconds, true
+	case time.Second:
+		return timeEncodingSeconds, true
+	case time.Millisecond:
+		return timeEncodingMilliseconds, true
+	case time.Microsecond:
+		return timeEncodingMicroseconds, true
+	case time.Nanosecond:
+		return timeEncodingNanoseconds, true
+	default:
+		return 0, false
+	}
+}
+
+// appendTimestamp appends the timestamp of the now in the encoding.
+func appendTimestamp(dst []byte, encoding byte, now time.Time) []byte {
+	if encoding == timeEncodingUnixSeconds {
+		return append(dst, getBytesForInt64(now.Unix())...)
+	}
+
+	units := (now.UnixNano() - epochNano) / timeEncodingUnits[encoding]
+	if units < 0 {
+		units = 0
+	}
+
+	return binary.AppendUvarint(dst, uint64(units))
+}
+
+// readTimestamp reads the timestamp in the encoding from the buf and returns it with the number of read bytes.
+// n <= 0 means that the buf is malformed.
+func readTimestamp(buf []byte, encoding byte) (t time.Time, n int) {
+	if encoding == timeEncodingUnixSeconds {
+		if len(buf) < 8 {
+			return time.Time{}, 0
+		}
+
+		return time.Unix(getInt64(buf), 0), 8
+	}
+
+	units, n := getUvarint(buf)
+	if n <= 0 || units > uint64((maxInt64-epochNano)/timeEncodingUnits[encoding]) {
+		return time.Time{}, 0
+	}
+
+	return time.Unix(0, epochNano+int64(units)*timeEncodingUnits[encoding]), n
+}
+
+const maxInt64 = 1<<63 - 1
@@ -0,0 +1,411 @@
+package fst
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Unix signer protocol:
+// request:  [1 byte op] [uvarint count] count*([uvarint len] [message]) (op == verify: count*([uvarint len] [signature]))
+// response: [1 byte status] (status == ok: op == sign: count*([uvarint len] [signature]), op == verify: count*[1 byte valid])
+//           (status == error: [uvarint len] [error message])
+
+const (
+	unixSignerOpSign   = 1
+	unixSignerOpVerify = 2
+
+	unixSignerStatusOk    = 0
+	unixSignerStatusError = 1
+
+	unixSignerMaxCount   = 1 << 16
+	unixSignerMaxItemLen = 1 << 24
+)
+
+// UnixSignerServer is a reference RemoteSigner backend that keeps the key and serves UnixSigner over a Unix socket.
+// It can stand in for a KMS in tests.
+//
+// # Example:
+//
+//	server, err := fst.ListenUnixSigner("/tmp/fst.sock", fst.NewKey("kms", []byte(`secret`), sha256.New))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer server.Close()
+//
+//	signer := fst.NewUnixSigner("/tmp/fst.sock")
+//	defer signer.Close()
+//
+//	converter := fst.NewConverter(&fst.ConverterConfig{
+//		Signer:            signer,
+//		VerifiedCacheSize: 1024,
+//	})
+type UnixSignerServer struct {
+	key      *Key
+	listener net.Listener
+
+	wg sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// ListenUnixSigner creates a new UnixSignerServer that listens on the path and signs with the key.
+func ListenUnixSigner(path string, key *Key) (*UnixSignerServer, error) {
+	if key.asymmetric {
+		return nil, SymmetricKeyRequired
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &UnixSignerServer{
+		key:      key,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	server.wg.Add(1)
+	go server.serve()
+
+	return server, nil
+}
+
+// Close stops the server and closes all connections.
+func (s *UnixSignerServer) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *UnixSignerServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *UnixSignerServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		messages, err := readUnixSignerItems(r)
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case unixSignerOpSign:
+			if s.key.isClosed() {
+				writeUnixSignerError(w, "the key is closed")
+				break
+			}
+
+			w.WriteByte(unixSignerStatusOk)
+			for _, message := range messages {
+				writeUnixSignerItem(w, s.sign(message))
+			}
+		case unixSignerOpVerify:
+			signatures, err := readUnixSignerItems(r)
+			if err != nil {
+				return
+			}
+
+			if len(signatures) != len(messages) {
+				writeUnixSignerError(w, "the number of signatures does not match the number of messages")
+				break
+			}
+
+			mac := s.key.algorithmMac(AlgorithmHMAC)
+			w.WriteByte(unixSignerStatusOk)
+			for i, message := range messages {
+				if len(signatures[i]) == mac.Size() && mac.verify(signatures[i], message, nil, nil) {
+					w.WriteByte(1)
+				} else {
+					w.WriteByte(0)
+				}
+			}
+		default:
+			writeUnixSignerError(w, "unknown operation")
+		}
+
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *UnixSignerServer) sign(message []byte) []byte {
+	return s.key.algorithmMac(AlgorithmHMAC).sum(nil, message, nil, nil)
+}
+
+// UnixSigner is a RemoteSigner that delegates signing to the UnixSignerServer.
+// It reuses the connections, so call Close when it is no longer needed.
+type UnixSigner struct {
+	path string
+
+	mu   sync.Mutex
+	idle []*unixSignerConn
+}
+
+type unixSignerConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewUnixSigner creates a new UnixSigner that connects to the UnixSignerServer listening on the path.
+func NewUnixSigner(path string) *UnixSigner {
+	return &UnixSigner{
+		path: path,
+	}
+}
+
+// Sign returns the signatures of the messages.
+func (s *UnixSigner) Sign(ctx context.Context, messages [][]byte) ([][]byte, error) {
+	var signatures [][]byte
+
+	err := s.do(ctx, func(c *unixSignerConn) error {
+		c.w.WriteByte(unixSignerOpSign)
+		writeUnixSignerItems(c.w, messages)
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+
+		if err := readUnixSignerStatus(c.r); err != nil {
+			return err
+		}
+
+		signatures = make([][]byte, len(messages))
+		for i := range signatures {
+			signature, err := readUnixSignerItem(c.r)
+			if err != nil {
+				return err
+			}
+			signatures[i] = signature
+		}
+
+		return nil
+	})
+
+	return signatures, err
+}
+
+// Verify reports for each message whether its signature is valid.
+func (s *UnixSigner) Verify(ctx context.Context, messages, signatures [][]byte) ([]bool, error) {
+	var valid []bool
+
+	err := s.do(ctx, func(c *unixSignerConn) error {
+		c.w.WriteByte(unixSignerOpVerify)
+		writeUnixSignerItems(c.w, messages)
+		writeUnixSignerItems(c.w, signatures)
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+
+		if err := readUnixSignerStatus(c.r); err != nil {
+			return err
+		}
+
+		valid = make([]bool, len(messages))
+		for i := range valid {
+			b, err := c.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			valid[i] = b == 1
+		}
+
+		return nil
+	})
+
+	return valid, err
+}
+
+// Close closes the idle connections.
+func (s *UnixSigner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.idle {
+		c.conn.Close()
+	}
+	s.idle = nil
+
+	return nil
+}
+
+// do runs the call on an idle or a new connection. The connection is closed if the call fails or the ctx is done.
+// The error of the ctx is returned only if the call fails, so a completed call is never reported as canceled.
+func (s *UnixSigner) do(ctx context.Context, call func(c *unixSignerConn) error) error {
+	c, err := s.getConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+
+	err = call(c)
+	if !stop() || err != nil {
+		// The result is complete even if the ctx is done after it is read, but the connection has the expired deadline.
+		c.conn.Close()
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return err
+	}
+
+	s.mu.Lock()
+	s.idle = append(s.idle, c)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *UnixSigner) getConn(ctx context.Context) (*unixSignerConn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unixSignerConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}, nil
+}
+
+func writeUnixSignerItem(w *bufio.Writer, item []byte) {
+	var length [binary.MaxVarintLen64]byte
+	w.Write(length[:binary.PutUvarint(length[:], uint64(len(item)))])
+	w.Write(item)
+}
+
+func writeUnixSignerItems(w *bufio.Writer, items [][]byte) {
+	var length [binary.MaxVarintLen64]byte
+	w.Write(length[:binary.PutUvarint(length[:], uint64(len(items)))])
+	for _, item := range items {
+		writeUnixSignerItem(w, item)
+	}
+}
+
+func writeUnixSignerError(w *bufio.Writer, message string) {
+	w.WriteByte(unixSignerStatusError)
+	writeUnixSignerItem(w, []byte(message))
+}
+
+func readUnixSignerItem(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if length > unixSignerMaxItemLen {
+		return nil, errors.New("fst: unix signer item is too large")
+	}
+
+	item := make([]byte, length)
+	if _, err = io.ReadFull(r, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func readUnixSignerItems(r *bufio.Reader) ([][]byte, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > unixSignerMaxCount {
+		return nil, errors.New("fst: too many unix signer items")
+	}
+
+	items := make([][]byte, count)
+	for i := range items {
+		if items[i], err = readUnixSignerItem(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func readUnixSignerStatus(r *bufio.Reader) error {
+	status, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	if status == unixSignerStatusOk {
+		return nil
+	}
+
+	message, err := readUnixSignerItem(r)
+	if err != nil {
+		return err
+	}
+
+	return errors.New("fst: unix signer: " + string(message))
+}
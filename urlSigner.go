@@ -0,0 +1,192 @@
+package fst
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// URLNotSigned means that the URL has no token.
+var URLNotSigned = errors.New("fst: url is not signed")
+
+// The value of the URL token is [8 bytes expiration Unix seconds] [32 bytes SHA-256 of the canonical request].
+// The canonical request is the method, the escaped path and the signed query parameters, each prefixed with its uvarint length.
+const urlTokenValueSize = 8 + sha256.Size
+
+// URLSigner represents a signer of pre-signed URLs, like download links.
+// It signs the method, the path, the query parameters and the expiration time of the URL into a FST,
+// that is appended to the URL as a query parameter.
+//
+// # Example:
+//
+//	signer := fst.NewURLSigner(&fst.URLSignerConfig{
+//		Converter: fst.NewEncodedConverter(&fst.ConverterConfig{
+//			SecretKey: []byte(`secret`),
+//		}),
+//	})
+//
+//	link, err := signer.Sign(http.MethodGet, "https://example.com/files/report.pdf", time.Hour)
+//	if err != nil {
+//		fmt.Println(err)
+//	}
+//	fmt.Println(link) // https://example.com/files/report.pdf?fst=...
+//
+//	http.Handle("/files/", signer.Handler(files))
+type URLSigner struct {
+	converter    *EncodedConverter
+	signedParams []string
+	tokenParam   string
+	onError      func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// URLSignerConfig represents the configuration options for creating a new URLSigner.
+//
+// Converter is the EncodedConverter used to create and parse the tokens.
+//
+// SignedParams are the query parameters that are signed. It is nil by default and all query parameters are signed.
+//
+// TokenParam is the name of the query parameter with the token. It is "fst" by default.
+//
+// OnError is called by the Handler when the URL is rejected. It responds with 403 Forbidden by default.
+type URLSignerConfig struct {
+	// Converter is the EncodedConverter used to create and parse the tokens.
+	// Use a Converter with its own secret or Postfix, so the tokens of the URLs and other tokens can not replace each other.
+	Converter *EncodedConverter
+	// SignedParams are the query parameters that are signed. The other parameters can be added or changed by anyone.
+	// It is nil by default and all query parameters are signed.
+	SignedParams []string
+	// TokenParam is the name of the query parameter with the token. It is "fst" by default.
+	TokenParam string
+	// OnError is called by the Handler when the URL is rejected. It responds with 403 Forbidden by default.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewURLSigner creates a new instance of the URLSigner based on the provided fst.URLSignerConfig.
+//
+// An example of usage can be found at URLSigner.
+func NewURLSigner(cfg *URLSignerConfig) *URLSigner {
+	if cfg.Converter == nil {
+		panic("fst: URLSignerConfig.Converter is nil")
+	}
+
+	signer := &URLSigner{
+		converter:    cfg.Converter,
+		signedParams: cfg.SignedParams,
+		tokenParam:   cfg.TokenParam,
+		onError:      cfg.OnError,
+	}
+
+	if signer.tokenParam == "" {
+		signer.tokenParam = "fst"
+	}
+
+	if signer.onError == nil {
+		signer.onError = func(w http.ResponseWriter, _ *http.Request, _ error) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}
+
+	return signer
+}
+
+// Sign returns the rawURL with the token that allows the method for the expirationTime.
+//
+// It can return the error of url.Parse.
+func (s *URLSigner) Sign(method, rawURL string, expirationTime time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Del(s.tokenParam)
+
+	value := make([]byte, 0, urlTokenValueSize)
+	value = append(value, getBytesForInt64(s.converter.converter.clock().Add(expirationTime).Unix())...)
+	value = s.appendDigest(value, method, u.EscapedPath(), query)
+
+	query.Set(s.tokenParam, s.converter.NewToken(value))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Verify checks that the URL of the r is signed by Sign for the method of the r and is not expired.
+//
+// It can return errors like URLNotSigned, InvalidTokenFormat, InvalidSignature, TokenExpired.
+func (s *URLSigner) Verify(r *http.Request) error {
+	query := r.URL.Query()
+
+	token := query.Get(s.tokenParam)
+	if token == "" {
+		return URLNotSigned
+	}
+	query.Del(s.tokenParam)
+
+	value, err := s.converter.ParseToken(token)
+	if err != nil {
+		return err
+	}
+
+	if len(value) != urlTokenValueSize {
+		return InvalidTokenFormat
+	}
+
+	digest := s.appendDigest(make([]byte, 0, sha256.Size), r.Method, r.URL.EscapedPath(), query)
+	if !hmac.Equal(value[8:], digest) {
+		return InvalidSignature
+	}
+
+	if getInt64(value) < s.converter.converter.clock().Unix() {
+		return TokenExpired
+	}
+
+	return nil
+}
+
+// Handler returns the http.Handler that calls the next only for the requests with valid signed URLs.
+// Other requests are passed to the OnError.
+func (s *URLSigner) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Verify(r); err != nil {
+			s.onError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// appendDigest appends the SHA-256 of the canonical request to the dst.
+func (s *URLSigner) appendDigest(dst []byte, method, path string, query url.Values) []byte {
+	h := sha256.New()
+	writeCanonical(h, method)
+	writeCanonical(h, path)
+
+	if s.signedParams == nil {
+		// url.Values.Encode sorts the parameters, so the order of the parameters in the URL does not matter.
+		writeCanonical(h, query.Encode())
+	} else {
+		for _, param := range s.signedParams {
+			values := query[param]
+			writeCanonical(h, param)
+			h.Write(binary.AppendUvarint(nil, uint64(len(values))))
+			for _, v := range values {
+				writeCanonical(h, v)
+			}
+		}
+	}
+
+	return h.Sum(dst)
+}
+
+// writeCanonical writes the s prefixed with its uvarint length, so the boundaries of the parts can not be moved.
+func writeCanonical(h hash.Hash, s string) {
+	h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	h.Write([]byte(s))
+}
@@ -0,0 +1,127 @@
+package fst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner(&URLSignerConfig{
+		Converter: NewEncodedConverter(&ConverterConfig{
+			SecretKey: []byte(`secret`),
+		}),
+	})
+
+	link, err := signer.Sign(http.MethodGet, "https://example.com/files/report.pdf?b=2&a=1", time.Minute)
+	if err != nil {
+		t.Fatal("Sign err: ", err)
+	}
+
+	if err = signer.Verify(httptest.NewRequest(http.MethodGet, link, nil)); err != nil {
+		t.Fatal("Signed URL verify err: ", err)
+	}
+
+	tampered := []struct {
+		method string
+		link   string
+		err    error
+	}{
+		{http.MethodDelete, link, InvalidSignature},
+		{http.MethodGet, replaceInURL(t, link, func(u *url.URL) { u.Path = "/files/secret.pdf" }), InvalidSignature},
+		{http.MethodGet, replaceInURL(t, link, func(u *url.URL) { setQuery(u, "a", "2") }), InvalidSignature},
+		{http.MethodGet, replaceInURL(t, link, func(u *url.URL) { setQuery(u, "c", "3") }), InvalidSignature},
+		{http.MethodGet, replaceInURL(t, link, func(u *url.URL) { setQuery(u, "fst", "") }), URLNotSigned},
+	}
+
+	for _, test := range tampered {
+		err = signer.Verify(httptest.NewRequest(test.method, test.link, nil))
+		if !errors.Is(err, test.err) {
+			t.Error(test.method, " ", test.link, " verify err: ", err)
+		}
+	}
+
+	expired, err := signer.Sign(http.MethodGet, "https://example.com/files/report.pdf", -time.Minute)
+	if err != nil {
+		t.Fatal("Sign err: ", err)
+	}
+	if err = signer.Verify(httptest.NewRequest(http.MethodGet, expired, nil)); !errors.Is(err, TokenExpired) {
+		t.Fatal("Expired URL verify err: ", err)
+	}
+}
+
+func TestURLSigner_SignedParams(t *testing.T) {
+	signer := NewURLSigner(&URLSignerConfig{
+		Converter: NewEncodedConverter(&ConverterConfig{
+			SecretKey: []byte(`secret`),
+		}),
+		SignedParams: []string{"user"},
+		TokenParam:   "signature",
+	})
+
+	link, err := signer.Sign(http.MethodGet, "https://example.com/download?user=1&utm_source=mail", time.Minute)
+	if err != nil {
+		t.Fatal("Sign err: ", err)
+	}
+
+	handler := signer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for link, status := range map[string]int{
+		link: http.StatusNoContent,
+		replaceInURL(t, link, func(u *url.URL) { setQuery(u, "utm_source", "ads") }): http.StatusNoContent,
+		replaceInURL(t, link, func(u *url.URL) { setQuery(u, "user", "2") }):         http.StatusForbidden,
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, link, nil))
+		if recorder.Code != status {
+			t.Error(link, " status is ", recorder.Code)
+		}
+	}
+}
+
+func replaceInURL(t *testing.T, rawURL string, replace func(u *url.URL)) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace(u)
+
+	return u.String()
+}
+
+func setQuery(u *url.URL, key, value string) {
+	query := u.Query()
+	if value == "" {
+		query.Del(key)
+	} else {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+}
+
+func TestURLSigner_Now(t *testing.T) {
+	now := time.Now()
+	signer := NewURLSigner(&URLSignerConfig{
+		Converter: NewEncodedConverter(&ConverterConfig{
+			SecretKey: []byte(`secret`),
+			Now: func() time.Time {
+				return now
+			},
+		}),
+	})
+
+	link, err := signer.Sign(http.MethodGet, "https://example.com/files/report.pdf", time.Minute)
+	if err != nil {
+		t.Fatal("Sign err: ", err)
+	}
+
+	now = now.Add(time.Minute * 2)
+	if err = signer.Verify(httptest.NewRequest(http.MethodGet, link, nil)); !errors.Is(err, TokenExpired) {
+		t.Fatal("Signed URL verify err after the clock moved: ", err)
+	}
+}
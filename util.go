@@ -1,12 +1,14 @@
 package fst
 
+import "encoding/binary"
+
 func getSizeForLen(len int) int {
 	if len < 255 {
 		return 1
 	} else if len < 65535 {
-		return 2
-	} else {
 		return 3
+	} else {
+		return 6
 	}
 }
 
@@ -23,14 +25,35 @@ func getLenAndSize(buf []byte) (int, int) {
 	return int(buf[5])<<16 | int(buf[4])<<8 | int(buf[3]), 6
 }
 
-func getBytesFromLen(len int) []byte {
+func appendLen(dst []byte, len int) []byte {
 	if len < 255 {
-		return []byte{byte(len)}
+		return append(dst, byte(len))
 	} else if len < 65535 {
-		return []byte{byte(255), byte(len), byte(len >> 8)}
+		return append(dst, byte(255), byte(len), byte(len>>8))
+	}
+
+	return append(dst, byte(255), byte(255), byte(255), byte(len), byte(len>>8), byte(len>>16))
+}
+
+func getSizeForUvarint(v uint64) int {
+	size := 1
+	for v >= 0x80 {
+		v >>= 7
+		size++
+	}
+
+	return size
+}
+
+// getUvarint reads a uvarint from the buf and returns it with the number of read bytes.
+// Unlike binary.Uvarint, it rejects non-minimal encodings. n <= 0 means that the buf is malformed.
+func getUvarint(buf []byte) (uint64, int) {
+	v, n := binary.Uvarint(buf)
+	if n > 1 && buf[n-1] == 0 {
+		return 0, 0
 	}
 
-	return []byte{byte(255), byte(255), byte(255), byte(len), byte(len >> 8), byte(len >> 16)}
+	return v, n
 }
 
 func getBytesForInt64(i int64) []byte {